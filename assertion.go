@@ -0,0 +1,156 @@
+package promtest
+
+import (
+	"errors"
+	"fmt"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// MetricAssertion chains assertions on a single series of a snapshot, e.g.
+//
+//	s.Histogram("request_seconds").WithLabels(labels).CountEquals(3).BucketLE(0.5, 2)
+//
+// Every failed assertion of the chain is reported, except that a series that cannot be found is
+// only reported by the first assertion of the chain.
+type MetricAssertion struct {
+	s            *Snapshot
+	metricType   dto.MetricType
+	name         string
+	labels       map[string]string
+	lookupFailed bool
+}
+
+// Counter starts a chain of assertions on a counter in the snapshot.
+func (s *Snapshot) Counter(name string) *MetricAssertion {
+	return &MetricAssertion{s: s, metricType: dto.MetricType_COUNTER, name: name}
+}
+
+// Gauge starts a chain of assertions on a gauge in the snapshot.
+func (s *Snapshot) Gauge(name string) *MetricAssertion {
+	return &MetricAssertion{s: s, metricType: dto.MetricType_GAUGE, name: name}
+}
+
+// Summary starts a chain of assertions on a summary in the snapshot.
+func (s *Snapshot) Summary(name string) *MetricAssertion {
+	return &MetricAssertion{s: s, metricType: dto.MetricType_SUMMARY, name: name}
+}
+
+// Histogram starts a chain of assertions on a histogram in the snapshot.
+func (s *Snapshot) Histogram(name string) *MetricAssertion {
+	return &MetricAssertion{s: s, metricType: dto.MetricType_HISTOGRAM, name: name}
+}
+
+// WithLabels returns a copy of the chain asserting on the series with exactly the given labels.
+func (a *MetricAssertion) WithLabels(labels map[string]string) *MetricAssertion {
+	c := *a
+	c.labels = labels
+	return &c
+}
+
+// Equals asserts the value of a counter or gauge.
+func (a *MetricAssertion) Equals(value float64) *MetricAssertion {
+	a.s.t.Helper()
+	switch a.metricType {
+	case dto.MetricType_COUNTER:
+		return a.check(a.s.CheckCount(a.name, a.labels, value))
+	case dto.MetricType_GAUGE:
+		return a.check(a.s.CheckGauge(a.name, a.labels, value))
+	}
+	a.s.errorf(a.name, "Cannot assert the value of %s %s, use SumEquals or CountEquals", typeTitle(a.metricType), a.name)
+	return a
+}
+
+// SumEquals asserts the sample sum of a summary or histogram.
+func (a *MetricAssertion) SumEquals(sum float64) *MetricAssertion {
+	a.s.t.Helper()
+	metric, err := a.s.findMetric(a.metricType, a.name, a.labels)
+	if err != nil {
+		if sum == 0 && isNotFound(err) {
+			// Metric not existing is the same as the metric having 0 value
+			return a
+		}
+		return a.check(err)
+	}
+
+	actualSum := metric.GetSummary().GetSampleSum()
+	if a.metricType == dto.MetricType_HISTOGRAM {
+		actualSum = metric.GetHistogram().GetSampleSum()
+	}
+	if !a.s.floatEquals(actualSum, sum) {
+		return a.check(&ValueMismatchError{a.metricType, a.name, a.labels, "sample sum", sum, actualSum})
+	}
+	return a
+}
+
+// CountEquals asserts the sample count of a summary or histogram.
+func (a *MetricAssertion) CountEquals(count uint64) *MetricAssertion {
+	a.s.t.Helper()
+	metric, err := a.s.findMetric(a.metricType, a.name, a.labels)
+	if err != nil {
+		if count == 0 && isNotFound(err) {
+			// Metric not existing is the same as the metric having 0 value
+			return a
+		}
+		return a.check(err)
+	}
+
+	actualCount := metric.GetSummary().GetSampleCount()
+	if a.metricType == dto.MetricType_HISTOGRAM {
+		actualCount = metric.GetHistogram().GetSampleCount()
+	}
+	if actualCount != count {
+		return a.check(&ValueMismatchError{a.metricType, a.name, a.labels, "sample count",
+			float64(count), float64(actualCount)})
+	}
+	return a
+}
+
+// BucketLE asserts the cumulative count of the bucket with the upper bound le of a histogram.
+func (a *MetricAssertion) BucketLE(le float64, cumulativeCount uint64) *MetricAssertion {
+	a.s.t.Helper()
+	metric, err := a.s.findMetric(dto.MetricType_HISTOGRAM, a.name, a.labels)
+	if err != nil {
+		if cumulativeCount == 0 && isNotFound(err) {
+			// Histogram not existing is the same as the histogram having 0 value
+			return a
+		}
+		return a.check(err)
+	}
+
+	histogram := metric.GetHistogram()
+	bucket := findBucket(histogram, le)
+	if bucket == nil {
+		return a.check(a.s.missingBucketError(a.name, histogram, le))
+	}
+	if actualCount := bucket.GetCumulativeCount(); actualCount != cumulativeCount {
+		return a.check(fmt.Errorf("Expected %d observations of histogram [%s] to be <= %s but was %d",
+			cumulativeCount, a.name, a.s.formatValue(le), actualCount))
+	}
+	return a
+}
+
+// check reports err, if any, unless it is a failed lookup of the series that was already reported
+// by an earlier assertion of the chain.
+func (a *MetricAssertion) check(err error) *MetricAssertion {
+	a.s.t.Helper()
+	if err == nil {
+		return a
+	}
+	if isLookupError(err) {
+		if a.lookupFailed {
+			return a
+		}
+		a.lookupFailed = true
+	}
+	a.s.report(a.name, err)
+	return a
+}
+
+// isLookupError reports whether err is a failed lookup of a series, rather than a series not
+// holding the expected value.
+func isLookupError(err error) bool {
+	var typeMismatch *TypeMismatchError
+	var ambiguous *AmbiguousMatchError
+	return isNotFound(err) || errors.As(err, &typeMismatch) || errors.As(err, &ambiguous)
+}
@@ -0,0 +1,58 @@
+package promtest
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMetricAssertionChain(t *testing.T) {
+	labels := map[string]string{"code": "200"}
+	tests := []struct {
+		name     string
+		assert   func(s *Snapshot)
+		failures []string
+	}{
+		{
+			name:   "passing links",
+			assert: func(s *Snapshot) { s.Histogram("foo").WithLabels(labels).CountEquals(1).SumEquals(1).BucketLE(1, 1) },
+		},
+		{
+			name: "several failing links",
+			assert: func(s *Snapshot) {
+				s.Histogram("foo").WithLabels(labels).CountEquals(2).BucketLE(1, 0).BucketLE(5, 1).SumEquals(3)
+			},
+			failures: []string{
+				"Expected histogram [foo] sample count to be 2 but was 1",
+				"Expected 0 observations of histogram [foo] to be <= 1 but was 1",
+				"Histogram foo has no bucket with upper bound 5, its upper bounds are [1]",
+				"Expected histogram [foo] sample sum to be 3 but was 1",
+			},
+		},
+		{
+			name: "missing series",
+			assert: func(s *Snapshot) {
+				s.Histogram("foo").WithLabels(map[string]string{"code": "500"}).CountEquals(2).BucketLE(1, 1).SumEquals(3)
+			},
+			failures: []string{"Could not find Histogram foo with the labels map[code:500]"},
+		},
+		{
+			name:     "wrong type",
+			assert:   func(s *Snapshot) { s.Counter("foo").WithLabels(labels).Equals(1).Equals(2) },
+			failures: []string{"foo is a HISTOGRAM, not a COUNTER"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ft := &fakeTB{}
+			tt.assert(newSnapshotOf(t, ft, newFooHistogram()))
+			if len(ft.failures) != len(tt.failures) {
+				t.Fatalf("Expected %d failures but got %d: %q", len(tt.failures), len(ft.failures), ft.failures)
+			}
+			for i, failure := range tt.failures {
+				if !strings.Contains(ft.failures[i], failure) {
+					t.Errorf("Expected failure %d to contain %q but was %q", i, failure, ft.failures[i])
+				}
+			}
+		})
+	}
+}
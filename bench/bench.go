@@ -0,0 +1,87 @@
+// Package bench helps writing benchmarks which guard the overhead of instrumentation on hot paths,
+// e.g. a histogram observation in a request handler:
+//
+//	func BenchmarkObserve(b *testing.B) {
+//		r := bench.NewRegistry(b)
+//		h := prometheus.NewHistogram(prometheus.HistogramOpts{Name: "request_seconds", Help: "..."})
+//		r.MustRegister(h)
+//		r.MeasureObserve(h, []float64{0.01, 0.1, 1})
+//		r.AssertMaxAllocsPerOp(0)
+//	}
+package bench
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/ryanhall07/promtest"
+)
+
+// allocRuns is the number of runs over which allocations per operation are averaged
+const allocRuns = 100
+
+// Registry is a promtest.TestRegistry measuring the operations of a benchmark
+type Registry struct {
+	*promtest.TestRegistry
+	b *testing.B
+	// t reports the failures of the registry, b unless replaced by a test
+	t           promtest.TB
+	allocsPerOp float64
+	measured    bool
+}
+
+// NewRegistry allocates and initializes a new Registry reporting to b.
+func NewRegistry(b *testing.B, opts ...promtest.Option) *Registry {
+	return &Registry{TestRegistry: promtest.NewTestRegistry(b, opts...), b: b, t: b}
+}
+
+// Measure runs op b.N times as the timed part of the benchmark, then measures the allocations of
+// op, reported as metric-allocs/op to tell them apart from the allocs/op of -benchmem, which
+// include the allocations of the benchmark itself.
+func (r *Registry) Measure(op func()) {
+	r.b.Helper()
+	r.b.ResetTimer()
+	r.b.StartTimer()
+	for i := 0; i < r.b.N; i++ {
+		op()
+	}
+	r.b.StopTimer()
+
+	r.allocsPerOp = testing.AllocsPerRun(allocRuns, op)
+	r.measured = true
+	r.b.ReportMetric(r.allocsPerOp, "metric-allocs/op")
+}
+
+// MeasureObserve measures the observation of values, in turn, by o, e.g. a histogram or summary.
+func (r *Registry) MeasureObserve(o prometheus.Observer, values []float64) {
+	r.b.Helper()
+	if len(values) == 0 {
+		r.t.Fatal("Cannot measure observations without values")
+		return
+	}
+	i := 0
+	r.Measure(func() {
+		o.Observe(values[i%len(values)])
+		i++
+	})
+}
+
+// MeasureAdd measures the addition of value to c, e.g. a counter or gauge.
+func (r *Registry) MeasureAdd(c interface{ Add(float64) }, value float64) {
+	r.b.Helper()
+	r.Measure(func() {
+		c.Add(value)
+	})
+}
+
+// AssertMaxAllocsPerOp asserts that the last measured operation allocates at most n times.
+func (r *Registry) AssertMaxAllocsPerOp(n float64) {
+	r.t.Helper()
+	if !r.measured {
+		r.t.Error("Cannot assert allocations before measuring an operation")
+		return
+	}
+	if r.allocsPerOp > n {
+		r.t.Errorf("Expected at most %v allocations per operation but was %v", n, r.allocsPerOp)
+	}
+}
@@ -0,0 +1,109 @@
+package bench
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// fakeTB records the failures of the assertions under test instead of failing the benchmark.
+type fakeTB struct {
+	failures []string
+}
+
+func (t *fakeTB) Helper() {}
+
+func (t *fakeTB) Error(args ...interface{}) {
+	t.failures = append(t.failures, fmt.Sprint(args...))
+}
+
+func (t *fakeTB) Errorf(format string, args ...interface{}) {
+	t.failures = append(t.failures, fmt.Sprintf(format, args...))
+}
+
+func (t *fakeTB) Fatal(args ...interface{}) {
+	t.Error(args...)
+}
+
+func (t *fakeTB) Fatalf(format string, args ...interface{}) {
+	t.Errorf(format, args...)
+}
+
+// sink keeps the allocations of the measured operations from being optimized away
+var sink []byte
+
+// benchmark runs fn as a benchmark whose registry reports its failures to the returned fakeTB.
+func benchmark(fn func(r *Registry)) (testing.BenchmarkResult, *fakeTB) {
+	ft := &fakeTB{}
+	result := testing.Benchmark(func(b *testing.B) {
+		r := NewRegistry(b)
+		ft.failures = nil
+		r.t = ft
+		fn(r)
+	})
+	return result, ft
+}
+
+func TestMeasure(t *testing.T) {
+	result, ft := benchmark(func(r *Registry) {
+		r.Measure(func() { sink = make([]byte, 64) })
+		r.AssertMaxAllocsPerOp(1)
+	})
+	if len(ft.failures) != 0 {
+		t.Errorf("Expected no failure but got %q", ft.failures)
+	}
+	if allocs, ok := result.Extra["metric-allocs/op"]; !ok || allocs != 1 {
+		t.Errorf("Expected 1 metric-allocs/op to be reported but got %v", result.Extra)
+	}
+}
+
+func TestMeasureObserve(t *testing.T) {
+	h := prometheus.NewHistogram(prometheus.HistogramOpts{Name: "request_seconds", Help: "h"})
+	_, ft := benchmark(func(r *Registry) {
+		r.MustRegister(h)
+		r.MeasureObserve(h, []float64{0.01, 0.1, 1})
+		r.AssertMaxAllocsPerOp(0)
+	})
+	if len(ft.failures) != 0 {
+		t.Errorf("Expected observing a histogram not to allocate but got %q", ft.failures)
+	}
+}
+
+func TestAssertMaxAllocsPerOpFailures(t *testing.T) {
+	tests := []struct {
+		name    string
+		bench   func(r *Registry)
+		failure string
+	}{
+		{
+			name: "more allocations than allowed",
+			bench: func(r *Registry) {
+				r.Measure(func() { sink = make([]byte, 64) })
+				r.AssertMaxAllocsPerOp(0)
+			},
+			failure: "Expected at most 0 allocations per operation but was 1",
+		},
+		{
+			name:    "nothing measured",
+			bench:   func(r *Registry) { r.AssertMaxAllocsPerOp(0) },
+			failure: "Cannot assert allocations before measuring an operation",
+		},
+		{
+			name: "observations without values",
+			bench: func(r *Registry) {
+				r.MeasureObserve(prometheus.NewHistogram(prometheus.HistogramOpts{Name: "h", Help: "h"}), nil)
+			},
+			failure: "Cannot measure observations without values",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, ft := benchmark(tt.bench)
+			if len(ft.failures) != 1 || !strings.Contains(ft.failures[0], tt.failure) {
+				t.Errorf("Expected a single failure containing %q but got %q", tt.failure, ft.failures)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package promtest
+
+import (
+	"sync"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// Clock tells the time at which snapshots of a registry are taken
+type Clock interface {
+	Now() time.Time
+}
+
+// WithClock makes the snapshots of the registry take their time from c instead of the wall clock,
+// e.g. to make AssertCounterRateBetween deterministic.
+func WithClock(c Clock) Option {
+	return func(r *TestRegistry) {
+		r.clock = c
+	}
+}
+
+// FakeClock is a Clock which only moves when told to. It is safe for concurrent use.
+type FakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewFakeClock returns a clock stopped at now.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+// Now returns the current time of the clock.
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Advance moves the clock forward by d.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// Set moves the clock to now.
+func (c *FakeClock) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = now
+}
+
+// AssertTimestamp asserts the timestamp of a series in the snapshot, e.g. of a const metric
+// created with prometheus.NewMetricWithTimestamp. Timestamps have millisecond precision.
+func (s *Snapshot) AssertTimestamp(name string, labels map[string]string, want time.Time) {
+	s.t.Helper()
+	metric, ok := s.findSeries(name, labels)
+	if !ok {
+		return
+	}
+
+	if metric.TimestampMs == nil {
+		s.errorf(name, "%s with the labels %v has no timestamp", name, labels)
+		return
+	}
+	if actual := time.Unix(0, metric.GetTimestampMs()*int64(time.Millisecond)); !actual.Equal(want.Truncate(time.Millisecond)) {
+		s.errorf(name, "Expected the timestamp of %s with the labels %v to be %v but was %v", name, labels, want, actual)
+	}
+}
+
+// AssertCreatedTimestamp asserts the created timestamp of a counter, summary or histogram series in
+// the snapshot, i.e. the time the series was created at.
+func (s *Snapshot) AssertCreatedTimestamp(name string, labels map[string]string, want time.Time) {
+	s.t.Helper()
+	metric, ok := s.findSeries(name, labels)
+	if !ok {
+		return
+	}
+
+	created := metric.GetCounter().GetCreatedTimestamp()
+	if metric.Summary != nil {
+		created = metric.GetSummary().GetCreatedTimestamp()
+	} else if metric.Histogram != nil {
+		created = metric.GetHistogram().GetCreatedTimestamp()
+	}
+	if created == nil {
+		s.errorf(name, "%s with the labels %v has no created timestamp", name, labels)
+		return
+	}
+	if actual := created.AsTime(); !actual.Equal(want) {
+		s.errorf(name, "Expected the created timestamp of %s with the labels %v to be %v but was %v",
+			name, labels, want, actual)
+	}
+}
+
+// findSeries returns the series of a family of any type with exactly the given labels, failing the
+// test if there is none.
+func (s *Snapshot) findSeries(name string, labels map[string]string) (*dto.Metric, bool) {
+	s.t.Helper()
+	family, ok := s.family(name)
+	if !ok {
+		s.errorf(name, "Could not find metric %s", name)
+		return nil, false
+	}
+	metric, err := s.findMetric(family.GetType(), name, labels)
+	if err != nil {
+		s.report(name, err)
+		return nil, false
+	}
+	return metric, true
+}
@@ -0,0 +1,138 @@
+package promtest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// constCollector collects fixed metrics, e.g. with timestamps.
+type constCollector []prometheus.Metric
+
+func (c constCollector) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(c, ch)
+}
+
+func (c constCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, m := range c {
+		ch <- m
+	}
+}
+
+func TestFakeClock(t *testing.T) {
+	start := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	if now := clock.Now(); !now.Equal(start) {
+		t.Errorf("Expected the clock to be stopped at %v but was %v", start, now)
+	}
+	clock.Advance(90 * time.Second)
+	if now, expected := clock.Now(), start.Add(90*time.Second); !now.Equal(expected) {
+		t.Errorf("Expected the clock to be advanced to %v but was %v", expected, now)
+	}
+	clock.Set(start)
+	if now := clock.Now(); !now.Equal(start) {
+		t.Errorf("Expected the clock to be set to %v but was %v", start, now)
+	}
+}
+
+func TestWithClockRate(t *testing.T) {
+	ft := &fakeTB{}
+	clock := NewFakeClock(time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC))
+	r := NewTestRegistry(ft, WithClock(clock))
+	c := prometheus.NewCounter(prometheus.CounterOpts{Name: "foo_total", Help: "foo"})
+	r.MustRegister(c)
+	before, err := r.TakeSnapshot()
+	if err != nil {
+		t.Fatalf("Could not take a snapshot: %v", err)
+	}
+	c.Add(30)
+	clock.Advance(10 * time.Second)
+	after, err := r.TakeSnapshot()
+	if err != nil {
+		t.Fatalf("Could not take a snapshot: %v", err)
+	}
+
+	after.AssertCounterRateBetween(before, "foo_total", nil, 3, 3)
+	if len(ft.failures) != 0 {
+		t.Fatalf("Expected a rate of 3 per second but got %q", ft.failures)
+	}
+	after.AssertCounterRateBetween(before, "foo_total", nil, 4, 5)
+	assertSingleFailure(t, ft, "Expected counter [foo_total] rate to be in [4, 5] per second but was 3 (30 over 10s)")
+
+	ft.failures = nil
+	before.AssertCounterRateBetween(after, "foo_total", nil, 0, 1)
+	assertSingleFailure(t, ft, "the snapshot was taken -10s after the before snapshot")
+}
+
+func TestAssertTimestamps(t *testing.T) {
+	at := time.Date(2020, 6, 1, 12, 0, 0, 500e6, time.UTC)
+	desc := prometheus.NewDesc("foo", "foo", []string{"code"}, nil)
+	created, err := prometheus.NewConstMetricWithCreatedTimestamp(desc, prometheus.CounterValue, 1, at, "200")
+	if err != nil {
+		t.Fatalf("Could not create the metric: %v", err)
+	}
+	collector := constCollector{
+		prometheus.NewMetricWithTimestamp(at, prometheus.MustNewConstMetric(desc, prometheus.CounterValue, 2, "500")),
+		created,
+	}
+	labels := map[string]string{"code": "500"}
+	tests := []struct {
+		name    string
+		assert  func(s *Snapshot)
+		failure string
+	}{
+		{
+			name:   "AssertTimestamp",
+			assert: func(s *Snapshot) { s.AssertTimestamp("foo", labels, at) },
+		},
+		{
+			name:   "AssertTimestamp with millisecond precision",
+			assert: func(s *Snapshot) { s.AssertTimestamp("foo", labels, at.Add(time.Microsecond)) },
+		},
+		{
+			name:    "AssertTimestamp of another time",
+			assert:  func(s *Snapshot) { s.AssertTimestamp("foo", labels, at.Add(time.Second)) },
+			failure: "Expected the timestamp of foo with the labels map[code:500] to be 2020-06-01 12:00:01.5 +0000 UTC",
+		},
+		{
+			name:    "AssertTimestamp without a timestamp",
+			assert:  func(s *Snapshot) { s.AssertTimestamp("foo", map[string]string{"code": "200"}, at) },
+			failure: "foo with the labels map[code:200] has no timestamp",
+		},
+		{
+			name:    "AssertTimestamp of a missing metric",
+			assert:  func(s *Snapshot) { s.AssertTimestamp("bar", labels, at) },
+			failure: "Could not find metric bar",
+		},
+		{
+			name:   "AssertCreatedTimestamp",
+			assert: func(s *Snapshot) { s.AssertCreatedTimestamp("foo", map[string]string{"code": "200"}, at) },
+		},
+		{
+			name: "AssertCreatedTimestamp of another time",
+			assert: func(s *Snapshot) {
+				s.AssertCreatedTimestamp("foo", map[string]string{"code": "200"}, at.Add(-time.Hour))
+			},
+			failure: "Expected the created timestamp of foo with the labels map[code:200] to be 2020-06-01 11:00:00.5 +0000 UTC",
+		},
+		{
+			name:    "AssertCreatedTimestamp without a created timestamp",
+			assert:  func(s *Snapshot) { s.AssertCreatedTimestamp("foo", labels, at) },
+			failure: "foo with the labels map[code:500] has no created timestamp",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ft := &fakeTB{}
+			tt.assert(newSnapshotOf(t, ft, collector))
+			if tt.failure == "" {
+				if len(ft.failures) != 0 {
+					t.Errorf("Expected no failure but got %q", ft.failures)
+				}
+				return
+			}
+			assertSingleFailure(t, ft, tt.failure)
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package promtest
+
+import (
+	"runtime/debug"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ConstMetric describes a counter, gauge or untyped series expected from a collector
+type ConstMetric struct {
+	Name   string
+	Labels map[string]string
+	Value  float64
+}
+
+// AssertConstMetrics asserts that a collector, gathered in isolation, emits exactly the expected
+// series, e.g. a collector creating its metrics with prometheus.MustNewConstMetric.
+func (r *TestRegistry) AssertConstMetrics(c prometheus.Collector, expected []ConstMetric) {
+	r.t.Helper()
+	registry := prometheus.NewPedanticRegistry()
+	if err := registry.Register(c); err != nil {
+		r.t.Errorf("Could not register collector: %v", err)
+		return
+	}
+	families, err := registry.Gather()
+	if err != nil {
+		r.t.Errorf("Could not gather collector: %v", err)
+		return
+	}
+	snapshot := r.configure(NewSnapshotFromFamilies(r.t, families))
+
+	for _, series := range expected {
+		if !snapshot.hasSeries(series.Name, series.Labels) {
+			snapshot.errorf(series.Name, "Could not find %s with the labels %v", series.Name, series.Labels)
+			continue
+		}
+		family, _ := snapshot.family(series.Name)
+		if actualValue, ok := snapshot.seriesValue(family.GetType(), series.Name, series.Labels); ok &&
+			!snapshot.floatEquals(actualValue, series.Value) {
+			snapshot.errorf(series.Name, "Expected %s with the labels %v to be %f but was %f",
+				series.Name, series.Labels, series.Value, actualValue)
+		}
+	}
+
+	for _, name := range sortedNames(snapshot.MetricMap) {
+		for _, m := range snapshot.MetricMap[name].GetMetric() {
+			if labels := labelMap(m); !expectsConstMetric(expected, name, labels) {
+				snapshot.errorf(name, "Unexpected series %s%s", name, formatLabels(labels))
+			}
+		}
+	}
+}
+
+// AssertCollectorHealthy asserts that a collector, registered and gathered in isolation, neither
+// panics nor fails to be gathered.
+func (r *TestRegistry) AssertCollectorHealthy(c prometheus.Collector) {
+	r.t.Helper()
+	rc := &recoveringCollector{Collector: c}
+	registry := prometheus.NewPedanticRegistry()
+	err := registry.Register(rc)
+	if err == nil {
+		_, err = registry.Gather()
+	}
+
+	if p := rc.recovered(); p != nil {
+		r.t.Errorf("Collector panicked: %v\n%s", p.value, p.stack)
+		return
+	}
+	if err != nil {
+		r.t.Errorf("Could not gather collector: %v", err)
+	}
+}
+
+// recoveringCollector recovers from panics of the wrapped collector, which the registry would
+// otherwise not survive as it collects on separate goroutines
+type recoveringCollector struct {
+	prometheus.Collector
+
+	mu       sync.Mutex
+	panicked *recoveredPanic
+}
+
+type recoveredPanic struct {
+	value interface{}
+	stack []byte
+}
+
+func (c *recoveringCollector) Describe(ch chan<- *prometheus.Desc) {
+	defer c.recoverPanic()
+	c.Collector.Describe(ch)
+}
+
+func (c *recoveringCollector) Collect(ch chan<- prometheus.Metric) {
+	defer c.recoverPanic()
+	c.Collector.Collect(ch)
+}
+
+func (c *recoveringCollector) recoverPanic() {
+	if value := recover(); value != nil {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.panicked == nil {
+			c.panicked = &recoveredPanic{value: value, stack: debug.Stack()}
+		}
+	}
+}
+
+func (c *recoveringCollector) recovered() *recoveredPanic {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.panicked
+}
+
+func expectsConstMetric(expected []ConstMetric, name string, labels map[string]string) bool {
+	for _, series := range expected {
+		if series.Name == name && labelsEqual(series.Labels, labels) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,81 @@
+package promtest
+
+import (
+	dto "github.com/prometheus/client_model/go"
+)
+
+// AssertGaugesEqual asserts that two gauge series in the snapshot hold the same value.
+func (s *Snapshot) AssertGaugesEqual(nameA string, labelsA map[string]string, nameB string, labelsB map[string]string) {
+	s.t.Helper()
+	s.AssertValuesEqual(dto.MetricType_GAUGE, nameA, labelsA, dto.MetricType_GAUGE, nameB, labelsB)
+}
+
+// AssertValuesEqual asserts that two counter or gauge series in the snapshot, possibly of
+// different types, hold the same value.
+func (s *Snapshot) AssertValuesEqual(typeA dto.MetricType, nameA string, labelsA map[string]string,
+	typeB dto.MetricType, nameB string, labelsB map[string]string) {
+	s.t.Helper()
+	valueA, ok := s.seriesValue(typeA, nameA, labelsA)
+	if !ok {
+		return
+	}
+	valueB, ok := s.seriesValue(typeB, nameB, labelsB)
+	if !ok {
+		return
+	}
+
+	if !s.floatEquals(valueA, valueB) {
+		s.errorf(nameA, "Expected %s %s with the labels %v to equal %s %s with the labels %v but was %f and %f",
+			typeTitle(typeA), nameA, labelsA, typeTitle(typeB), nameB, labelsB, valueA, valueB)
+	}
+}
+
+// seriesValue returns the value of a counter, gauge or untyped series, failing the test if there
+// is no such series.
+func (s *Snapshot) seriesValue(metricType dto.MetricType, name string, labels map[string]string) (float64, bool) {
+	s.t.Helper()
+	metric, err := s.findMetric(metricType, name, labels)
+	if err != nil {
+		s.report(name, err)
+		return 0, false
+	}
+
+	if value, ok := singleValue(metricType, metric); ok {
+		return value, true
+	}
+	s.errorf(name, "%s %s does not have a single value", typeTitle(metricType), name)
+	return 0, false
+}
+
+// singleValue returns the value of a counter, gauge or untyped series.
+func singleValue(metricType dto.MetricType, m *dto.Metric) (float64, bool) {
+	switch metricType {
+	case dto.MetricType_COUNTER:
+		return m.GetCounter().GetValue(), true
+	case dto.MetricType_GAUGE:
+		return m.GetGauge().GetValue(), true
+	case dto.MetricType_UNTYPED:
+		return m.GetUntyped().GetValue(), true
+	}
+	return 0, false
+}
+
+// AssertInvariant asserts that a counter, gauge or untyped series in the snapshot holds the value
+// computed from the snapshot by expected, e.g. the difference of a started and a finished counter.
+func (s *Snapshot) AssertInvariant(name string, labels map[string]string, expected func(s *Snapshot) float64) {
+	s.t.Helper()
+	family, ok := s.family(name)
+	if !ok {
+		s.errorf(name, "Could not find metric %s", name)
+		return
+	}
+	actualValue, ok := s.seriesValue(family.GetType(), name, labels)
+	if !ok {
+		return
+	}
+
+	if expectedValue := expected(s); !s.floatEquals(actualValue, expectedValue) {
+		s.errorf(name, "Expected %s with the labels %v to equal the computed value %f but was %f",
+			name, labels, expectedValue, actualValue)
+	}
+}
@@ -0,0 +1,105 @@
+package promtest
+
+import (
+	"math"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// Comparison replaces the equality within the tolerance of the snapshot of assertions such as
+// AssertCount, AssertGauge, AssertSummary and AssertHistogram, e.g.
+//
+//	s.AssertCount("bytes_total", nil, 1e12, promtest.WithinRel(0.01))
+//	s.AssertGauge("queue_length", nil, 0, promtest.Between(1, 10))
+//
+// Comparisons that do not depend on the expected value, such as GreaterThan and Between, ignore it.
+// Several comparisons must all hold.
+type Comparison struct {
+	holds    func(expected, actual float64) bool
+	describe func(expected float64, format func(float64) string) string
+}
+
+// WithinAbs holds when the actual value differs from the expected value by at most epsilon.
+func WithinAbs(epsilon float64) Comparison {
+	return Comparison{
+		holds: func(expected, actual float64) bool {
+			return math.Abs(actual-expected) <= epsilon || math.IsNaN(expected) && math.IsNaN(actual)
+		},
+		describe: func(expected float64, format func(float64) string) string {
+			return "within " + format(epsilon) + " of " + format(expected)
+		},
+	}
+}
+
+// WithinRel holds when the actual value differs from the expected value by at most a fraction of
+// the expected value, e.g. WithinRel(0.01) for 1%.
+func WithinRel(fraction float64) Comparison {
+	return Comparison{
+		holds: func(expected, actual float64) bool {
+			return actual == expected || math.Abs(actual-expected) <= fraction*math.Abs(expected) ||
+				math.IsNaN(expected) && math.IsNaN(actual)
+		},
+		describe: func(expected float64, format func(float64) string) string {
+			return "within " + formatFloat(fraction*100) + "% of " + format(expected)
+		},
+	}
+}
+
+// GreaterThan holds when the actual value is greater than min.
+func GreaterThan(min float64) Comparison {
+	return Comparison{
+		holds: func(expected, actual float64) bool {
+			return actual > min
+		},
+		describe: func(expected float64, format func(float64) string) string {
+			return "greater than " + format(min)
+		},
+	}
+}
+
+// Between holds when the actual value is within [min, max].
+func Between(min, max float64) Comparison {
+	return Comparison{
+		holds: func(expected, actual float64) bool {
+			return actual >= min && actual <= max
+		},
+		describe: func(expected float64, format func(float64) string) string {
+			return "between " + format(min) + " and " + format(max)
+		},
+	}
+}
+
+// satisfies reports whether actual satisfies every comparison, or equals expected within the
+// tolerance of the snapshot if there is none.
+func (s *Snapshot) satisfies(expected, actual float64, cmp []Comparison) bool {
+	if len(cmp) == 0 {
+		return s.floatEquals(actual, expected)
+	}
+	return s.failedComparison(expected, actual, cmp) == nil
+}
+
+// failedComparison returns the first comparison that actual does not satisfy, if any.
+func (s *Snapshot) failedComparison(expected, actual float64, cmp []Comparison) *Comparison {
+	for i := range cmp {
+		if !cmp[i].holds(expected, actual) {
+			return &cmp[i]
+		}
+	}
+	return nil
+}
+
+// compareValue returns an error if the actual value of a field of a series does not satisfy every
+// comparison, or does not equal expected within the tolerance of the snapshot if there is none.
+func (s *Snapshot) compareValue(metricType dto.MetricType, name string, labels map[string]string, field string,
+	expected, actual float64, cmp []Comparison) error {
+	if len(cmp) == 0 {
+		if !s.floatEquals(actual, expected) {
+			return &ValueMismatchError{metricType, name, labels, field, expected, actual}
+		}
+		return nil
+	}
+	if failed := s.failedComparison(expected, actual, cmp); failed != nil {
+		return &ComparisonError{metricType, name, labels, field, *failed, expected, actual}
+	}
+	return nil
+}
@@ -0,0 +1,296 @@
+package promtest
+
+import (
+	dto "github.com/prometheus/client_model/go"
+)
+
+// DeltaView provides methods for reasoning about how metrics changed between two snapshots. The
+// methods of a nil DeltaView, returned when a snapshot could not be taken, do nothing since the
+// failure was already reported.
+type DeltaView struct {
+	before *Snapshot
+	after  *Snapshot
+}
+
+// Measure takes a snapshot before and after running fn and returns the changes between them, or
+// nil if a snapshot could not be taken.
+func (r *TestRegistry) Measure(fn func()) *DeltaView {
+	r.t.Helper()
+	before, err := r.TakeSnapshot()
+	if err != nil {
+		r.t.Fatalf("Could not take a snapshot before running the measured function: %v", err)
+		return nil
+	}
+	fn()
+	after, err := r.TakeSnapshot()
+	if err != nil {
+		r.t.Fatalf("Could not take a snapshot after running the measured function: %v", err)
+		return nil
+	}
+	return Diff(before, after)
+}
+
+// Diff returns the changes between two snapshots, e.g. of a registry shared with other tests, or
+// nil if either snapshot is nil.
+func Diff(before, after *Snapshot) *DeltaView {
+	if before == nil || after == nil {
+		return nil
+	}
+	return &DeltaView{before: before, after: after}
+}
+
+// Diff returns the changes between the previous snapshot and this one, e.g. since the last
+// checkpoint of a long test.
+func (s *Snapshot) Diff(previous *Snapshot) *DeltaView {
+	return Diff(previous, s)
+}
+
+// AssertSeriesRemovedBy asserts that a series exists before running fn and no longer exists
+// afterwards, e.g. because fn called DeleteLabelValues or Reset on its vec.
+func (r *TestRegistry) AssertSeriesRemovedBy(name string, labels map[string]string, fn func()) {
+	r.t.Helper()
+	d := r.Measure(fn)
+	if d == nil {
+		return
+	}
+	if !d.before.hasSeries(name, labels) {
+		d.before.errorf(name, "Could not find a series of %s with the labels %v before running the function",
+			name, labels)
+		return
+	}
+	if d.after.hasSeries(name, labels) {
+		d.after.errorf(name, "Expected the series of %s with the labels %v to be removed but it still exists",
+			name, labels)
+	}
+}
+
+// AssertOnlyIncremented asserts that running fn increased a counter on the series with the given
+// labels and left every other series of the counter unchanged.
+func (r *TestRegistry) AssertOnlyIncremented(name string, labels map[string]string, fn func()) {
+	r.t.Helper()
+	d := r.Measure(fn)
+	if d == nil {
+		return
+	}
+	delta, err := d.delta(dto.MetricType_COUNTER, name, labels)
+	if err != nil {
+		d.after.report(name, err)
+		return
+	}
+	if delta <= 0 {
+		d.after.errorf(name, "Expected counter %s with the labels %v to increase but it changed by %f",
+			name, labels, delta)
+	}
+
+	family, _ := d.after.family(name)
+	for _, m := range family.GetMetric() {
+		other := labelMap(m)
+		if labelsEqual(other, labels) {
+			continue
+		}
+		if delta := d.CounterDelta(name, other); delta != 0 {
+			d.after.errorf(name, "Expected counter %s with the labels %v to be unchanged but it changed by %f",
+				name, other, delta)
+		}
+	}
+}
+
+// CounterDelta returns how much a counter changed. A missing counter counts as 0.
+func (d *DeltaView) CounterDelta(name string, labels map[string]string) float64 {
+	if d == nil {
+		return 0
+	}
+	d.after.t.Helper()
+	delta, err := d.delta(dto.MetricType_COUNTER, name, labels)
+	d.after.report(name, err)
+	return delta
+}
+
+// GaugeDelta returns how much a gauge changed. A missing gauge counts as 0.
+func (d *DeltaView) GaugeDelta(name string, labels map[string]string) float64 {
+	if d == nil {
+		return 0
+	}
+	d.after.t.Helper()
+	delta, err := d.delta(dto.MetricType_GAUGE, name, labels)
+	d.after.report(name, err)
+	return delta
+}
+
+// HistogramCountDelta returns how many observations a histogram gained. A missing histogram counts
+// as having no observations.
+func (d *DeltaView) HistogramCountDelta(name string, labels map[string]string) int64 {
+	if d == nil {
+		return 0
+	}
+	d.after.t.Helper()
+	delta, err := d.delta(dto.MetricType_HISTOGRAM, name, labels)
+	d.after.report(name, err)
+	return int64(delta)
+}
+
+// SummaryCountDelta returns how many observations a summary gained. A missing summary counts as
+// having no observations.
+func (d *DeltaView) SummaryCountDelta(name string, labels map[string]string) int64 {
+	if d == nil {
+		return 0
+	}
+	d.after.t.Helper()
+	delta, err := d.delta(dto.MetricType_SUMMARY, name, labels)
+	d.after.report(name, err)
+	return int64(delta)
+}
+
+// AssertCounterDelta asserts that a counter changed by exactly delta.
+func (d *DeltaView) AssertCounterDelta(name string, labels map[string]string, delta float64) {
+	if d == nil {
+		return
+	}
+	d.after.t.Helper()
+	actualDelta, err := d.delta(dto.MetricType_COUNTER, name, labels)
+	if err != nil {
+		d.after.report(name, err)
+		return
+	}
+	if !d.after.floatEquals(actualDelta, delta) {
+		d.after.errorf(name, "Expected counter [%s] to change by %f but changed by %f", name, delta, actualDelta)
+	}
+}
+
+// AssertCountIncreased asserts that a counter increased, by any amount.
+func (d *DeltaView) AssertCountIncreased(name string, labels map[string]string) {
+	if d == nil {
+		return
+	}
+	d.after.t.Helper()
+	actualDelta, err := d.delta(dto.MetricType_COUNTER, name, labels)
+	if err != nil {
+		d.after.report(name, err)
+		return
+	}
+	if actualDelta <= 0 {
+		d.after.errorf(name, "Expected counter [%s] to increase but changed by %f", name, actualDelta)
+	}
+}
+
+// AssertGaugeDelta asserts that a gauge changed by exactly delta.
+func (d *DeltaView) AssertGaugeDelta(name string, labels map[string]string, delta float64) {
+	if d == nil {
+		return
+	}
+	d.after.t.Helper()
+	actualDelta, err := d.delta(dto.MetricType_GAUGE, name, labels)
+	if err != nil {
+		d.after.report(name, err)
+		return
+	}
+	if !d.after.floatEquals(actualDelta, delta) {
+		d.after.errorf(name, "Expected gauge [%s] to change by %f but changed by %f", name, delta, actualDelta)
+	}
+}
+
+// AssertHistogramCountDelta asserts that a histogram gained exactly delta observations.
+func (d *DeltaView) AssertHistogramCountDelta(name string, labels map[string]string, delta int64) {
+	if d == nil {
+		return
+	}
+	d.after.t.Helper()
+	change, err := d.delta(dto.MetricType_HISTOGRAM, name, labels)
+	if err != nil {
+		d.after.report(name, err)
+		return
+	}
+	if actualDelta := int64(change); actualDelta != delta {
+		d.after.errorf(name, "Expected histogram [%s] sample count to change by %d but changed by %d",
+			name, delta, actualDelta)
+	}
+}
+
+// AssertSummaryCountDelta asserts that a summary gained exactly delta observations.
+func (d *DeltaView) AssertSummaryCountDelta(name string, labels map[string]string, delta int64) {
+	if d == nil {
+		return
+	}
+	d.after.t.Helper()
+	change, err := d.delta(dto.MetricType_SUMMARY, name, labels)
+	if err != nil {
+		d.after.report(name, err)
+		return
+	}
+	if actualDelta := int64(change); actualDelta != delta {
+		d.after.errorf(name, "Expected summary [%s] sample count to change by %d but changed by %d",
+			name, delta, actualDelta)
+	}
+}
+
+// AssertCountDelta asserts that a counter changed by exactly delta between the before snapshot and
+// this one. A counter missing from the before snapshot counts as 0.
+func (s *Snapshot) AssertCountDelta(before *Snapshot, name string, labels map[string]string, delta float64) {
+	s.t.Helper()
+	Diff(before, s).AssertCounterDelta(name, labels, delta)
+}
+
+// AssertCounterRateBetween asserts that a counter increased at a rate of [minPerSec, maxPerSec]
+// per second between the before snapshot and this one.
+func (s *Snapshot) AssertCounterRateBetween(before *Snapshot, name string, labels map[string]string,
+	minPerSec, maxPerSec float64) {
+	s.t.Helper()
+	if before == nil {
+		return
+	}
+	elapsed := s.takenAt.Sub(before.takenAt)
+	if elapsed <= 0 {
+		s.errorf(name, "Cannot compute the rate of counter %s: the snapshot was taken %v after the before snapshot",
+			name, elapsed)
+		return
+	}
+
+	delta, err := Diff(before, s).delta(dto.MetricType_COUNTER, name, labels)
+	if err != nil {
+		s.report(name, err)
+		return
+	}
+	if rate := delta / elapsed.Seconds(); rate < minPerSec || rate > maxPerSec {
+		s.errorf(name, "Expected counter [%s] rate to be in [%f, %f] per second but was %f (%f over %v)",
+			name, minPerSec, maxPerSec, rate, delta, elapsed)
+	}
+}
+
+// delta returns how much the value of a series changed, see valueOrZero. A type mismatch in
+// either snapshot is returned once, for the caller to report.
+func (d *DeltaView) delta(metricType dto.MetricType, name string, labels map[string]string) (float64, error) {
+	after, err := d.after.valueOrZero(metricType, name, labels)
+	if err != nil {
+		return 0, err
+	}
+	before, err := d.before.valueOrZero(metricType, name, labels)
+	if err != nil {
+		return 0, err
+	}
+	return after - before, nil
+}
+
+// valueOrZero returns the value of a counter or gauge, or the sample count of a summary or
+// histogram, treating a missing series as 0.
+func (s *Snapshot) valueOrZero(metricType dto.MetricType, name string, labels map[string]string) (float64, error) {
+	metric, err := s.findMetric(metricType, name, labels)
+	if err != nil {
+		if isNotFound(err) {
+			// Series not existing is the same as the series having 0 value
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	switch metricType {
+	case dto.MetricType_COUNTER:
+		return metric.GetCounter().GetValue(), nil
+	case dto.MetricType_GAUGE:
+		return metric.GetGauge().GetValue(), nil
+	case dto.MetricType_SUMMARY:
+		return float64(metric.GetSummary().GetSampleCount()), nil
+	case dto.MetricType_HISTOGRAM:
+		return float64(metric.GetHistogram().GetSampleCount()), nil
+	}
+	return metric.GetUntyped().GetValue(), nil
+}
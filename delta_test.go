@@ -0,0 +1,70 @@
+package promtest
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// failingCollector fails every gather.
+type failingCollector struct{}
+
+func (failingCollector) Describe(ch chan<- *prometheus.Desc) {}
+
+func (failingCollector) Collect(ch chan<- prometheus.Metric) {
+	ch <- prometheus.NewInvalidMetric(prometheus.NewDesc("broken", "broken", nil, nil), errors.New("broken"))
+}
+
+func TestMeasureWithoutSnapshot(t *testing.T) {
+	tests := map[string]func(r *TestRegistry){
+		"Measure": func(r *TestRegistry) {
+			d := r.Measure(func() {})
+			d.AssertCounterDelta("foo", nil, 1)
+			d.AssertCountIncreased("foo", nil)
+			d.AssertHistogramCountDelta("foo", nil, 1)
+		},
+		"AssertSeriesRemovedBy": func(r *TestRegistry) { r.AssertSeriesRemovedBy("foo", nil, func() {}) },
+		"AssertOnlyIncremented": func(r *TestRegistry) { r.AssertOnlyIncremented("foo", nil, func() {}) },
+	}
+	for name, assert := range tests {
+		t.Run(name, func(t *testing.T) {
+			ft := &fakeTB{}
+			r := NewTestRegistry(ft)
+			r.MustRegister(failingCollector{})
+			assert(r)
+			assertSingleFailure(t, ft, "Could not take a snapshot before running the measured function")
+		})
+	}
+}
+
+func TestDeltaTypeMismatchReportedOnce(t *testing.T) {
+	labels := map[string]string{"code": "200"}
+	clock := NewFakeClock(time.Unix(0, 0))
+	tests := map[string]func(r *TestRegistry){
+		"CounterDelta": func(r *TestRegistry) { r.Measure(func() {}).CounterDelta("foo", labels) },
+		"AssertCounterDelta": func(r *TestRegistry) {
+			r.Measure(func() {}).AssertCounterDelta("foo", labels, 0)
+		},
+		"AssertCountIncreased": func(r *TestRegistry) { r.Measure(func() {}).AssertCountIncreased("foo", labels) },
+		"AssertOnlyIncremented": func(r *TestRegistry) {
+			r.AssertOnlyIncremented("foo", labels, func() {})
+		},
+		"AssertCounterRateBetween": func(r *TestRegistry) {
+			before, _ := r.TakeSnapshot()
+			clock.Advance(time.Second)
+			after, _ := r.TakeSnapshot()
+			after.AssertCounterRateBetween(before, "foo", labels, 0, 1)
+		},
+	}
+	for name, assert := range tests {
+		t.Run(name, func(t *testing.T) {
+			ft := &fakeTB{}
+			r := NewTestRegistry(ft, WithClock(clock))
+			r.MustRegister(newFooGauge())
+			assert(r)
+			assertSingleFailure(t, ft, "foo is a GAUGE, not a COUNTER")
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package promtest
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// MetricNotFoundError is returned when no series of a metric matches the requested labels
+type MetricNotFoundError struct {
+	Type   dto.MetricType
+	Name   string
+	Labels map[string]string
+	// Closest holds the label sets of the series of the metric differing in the fewest labels from
+	// Labels, if any
+	Closest []map[string]string
+	// Matchers holds the label matchers of the snapshot view the series was looked up in, if any
+	Matchers []LabelMatcher
+}
+
+func (e *MetricNotFoundError) Error() string {
+	msg := fmt.Sprintf("Could not find %s %s with the labels %v", typeTitle(e.Type), e.Name, e.Labels)
+	if len(e.Matchers) > 0 {
+		msg += " matching " + formatMatchers(e.Matchers)
+	}
+	if len(e.Closest) == 0 {
+		return msg
+	}
+	msg += ", the closest series are:"
+	for _, labels := range e.Closest {
+		msg += fmt.Sprintf("\n%s%s: %s", e.Name, formatLabels(labels), describeLabelDiff(e.Labels, labels))
+	}
+	return msg
+}
+
+// describeLabelDiff describes how the actual labels of a series differ from the expected labels.
+func describeLabelDiff(expected, actual map[string]string) string {
+	names := make([]string, 0, len(expected)+len(actual))
+	for name := range expected {
+		names = append(names, name)
+	}
+	for name := range actual {
+		if _, ok := expected[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var diffs []string
+	for _, name := range names {
+		expectedValue, expectedOK := expected[name]
+		actualValue, actualOK := actual[name]
+		switch {
+		case !actualOK:
+			diffs = append(diffs, fmt.Sprintf("%s is missing", name))
+		case !expectedOK:
+			diffs = append(diffs, fmt.Sprintf("%s=%q is unexpected", name, actualValue))
+		case expectedValue != actualValue:
+			diffs = append(diffs, fmt.Sprintf("%s is %q, not %q", name, actualValue, expectedValue))
+		}
+	}
+	return strings.Join(diffs, ", ")
+}
+
+// ValueMismatchError is returned when a field of a series does not hold the expected value
+type ValueMismatchError struct {
+	Type     dto.MetricType
+	Name     string
+	Labels   map[string]string
+	Field    string
+	Expected float64
+	Actual   float64
+}
+
+func (e *ValueMismatchError) Error() string {
+	return e.message(formatFloat)
+}
+
+func (e *ValueMismatchError) message(format func(float64) string) string {
+	msg := fmt.Sprintf("Expected %s [%s] %s to be %s but was %s", strings.ToLower(typeTitle(e.Type)),
+		e.Name, e.Field, format(e.Expected), format(e.Actual))
+	return msg + nonFiniteHint(e.Actual)
+}
+
+// ComparisonError is returned when a field of a series does not satisfy a Comparison
+type ComparisonError struct {
+	Type       dto.MetricType
+	Name       string
+	Labels     map[string]string
+	Field      string
+	Comparison Comparison
+	Expected   float64
+	Actual     float64
+}
+
+func (e *ComparisonError) Error() string {
+	return e.message(formatFloat)
+}
+
+func (e *ComparisonError) message(format func(float64) string) string {
+	msg := fmt.Sprintf("Expected %s [%s] %s to be %s but was %s", strings.ToLower(typeTitle(e.Type)),
+		e.Name, e.Field, e.Comparison.describe(e.Expected, format), format(e.Actual))
+	return msg + nonFiniteHint(e.Actual)
+}
+
+// nonFiniteHint hints at the observation of a NaN or infinite value.
+func nonFiniteHint(actual float64) string {
+	switch {
+	case math.IsNaN(actual):
+		return ", did you observe a NaN value?"
+	case math.IsInf(actual, 0):
+		return ", did you observe an infinite value?"
+	}
+	return ""
+}
+
+// TypeMismatchError is returned when a metric family is not of the expected type
+type TypeMismatchError struct {
+	Name     string
+	Expected dto.MetricType
+	Actual   dto.MetricType
+}
+
+func (e *TypeMismatchError) Error() string {
+	return fmt.Sprintf("%s is a %s, not a %s",
+		e.Name, dto.MetricType_name[int32(e.Actual)], dto.MetricType_name[int32(e.Expected)])
+}
+
+// AmbiguousMatchError is returned when a subset of labels matches more than one series of a metric
+type AmbiguousMatchError struct {
+	Type    dto.MetricType
+	Name    string
+	Labels  map[string]string
+	Matches []map[string]string
+}
+
+func (e *AmbiguousMatchError) Error() string {
+	matches := make([]string, 0, len(e.Matches))
+	for _, labels := range e.Matches {
+		matches = append(matches, formatLabels(labels))
+	}
+	return fmt.Sprintf("The labels %v match %d series of %s %s: %s", e.Labels, len(e.Matches),
+		typeTitle(e.Type), e.Name, strings.Join(matches, ", "))
+}
+
+// typeTitle returns the name of a metric type as used in messages, e.g. "Counter"
+func typeTitle(metricType dto.MetricType) string {
+	name := dto.MetricType_name[int32(metricType)]
+	if name == "" {
+		return "Metric"
+	}
+	return name[:1] + strings.ToLower(name[1:])
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func isNotFound(err error) bool {
+	var notFound *MetricNotFoundError
+	return errors.As(err, &notFound)
+}
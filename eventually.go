@@ -0,0 +1,79 @@
+package promtest
+
+import (
+	"time"
+)
+
+// EventualAssertions re-gather a registry until an assertion passes, e.g. for metrics recorded by
+// background goroutines.
+type EventualAssertions struct {
+	r        *TestRegistry
+	timeout  time.Duration
+	interval time.Duration
+}
+
+// Eventually returns assertions which take a snapshot of the registry every interval until the
+// assertion passes, failing with the last snapshot once timeout expires.
+func (r *TestRegistry) Eventually(timeout, interval time.Duration) *EventualAssertions {
+	return &EventualAssertions{r: r, timeout: timeout, interval: interval}
+}
+
+// AssertCount eventually asserts existence and count of a counter.
+func (e *EventualAssertions) AssertCount(name string, labels map[string]string, value float64, cmp ...Comparison) {
+	e.r.t.Helper()
+	e.await(name, func(s *Snapshot) error {
+		return s.CheckCount(name, labels, value, cmp...)
+	})
+}
+
+// AssertGauge eventually asserts existence and value of a gauge.
+func (e *EventualAssertions) AssertGauge(name string, labels map[string]string, value float64, cmp ...Comparison) {
+	e.r.t.Helper()
+	e.await(name, func(s *Snapshot) error {
+		return s.CheckGauge(name, labels, value, cmp...)
+	})
+}
+
+// AssertSummary eventually asserts the existence and the sample sum and count of a summary.
+func (e *EventualAssertions) AssertSummary(name string, labels map[string]string, sum float64, count uint64,
+	cmp ...Comparison) {
+	e.r.t.Helper()
+	e.await(name, func(s *Snapshot) error {
+		return s.CheckSummary(name, labels, sum, count, cmp...)
+	})
+}
+
+// AssertHistogram eventually asserts the existence and the sample sum and count of a histogram.
+func (e *EventualAssertions) AssertHistogram(name string, labels map[string]string, sum float64, count uint64,
+	cmp ...Comparison) {
+	e.r.t.Helper()
+	e.await(name, func(s *Snapshot) error {
+		return s.CheckHistogram(name, labels, sum, count, cmp...)
+	})
+}
+
+// await takes snapshots until check passes or the timeout expires, then reports the last failure.
+// Only the first snapshot gathered is linted, so lint problems are reported once.
+func (e *EventualAssertions) await(name string, check func(s *Snapshot) error) {
+	e.r.t.Helper()
+	deadline := time.Now().Add(e.timeout)
+	lint := e.r.lint
+	for {
+		snapshot, err := e.r.takeSnapshot(lint, nil)
+		if err == nil {
+			lint = false
+			if err = check(snapshot); err == nil {
+				return
+			}
+		}
+		if time.Now().After(deadline) {
+			if snapshot == nil {
+				e.r.t.Errorf("Could not gather metrics within %v: %v", e.timeout, err)
+				return
+			}
+			snapshot.errorf(name, "%s after waiting %v", snapshot.renderError(err), e.timeout)
+			return
+		}
+		time.Sleep(e.interval)
+	}
+}
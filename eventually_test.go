@@ -0,0 +1,48 @@
+package promtest
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// newCollectsGauge returns a gauge of the number of times it was collected.
+func newCollectsGauge(name string) prometheus.Collector {
+	var collects int64
+	return prometheus.NewGaugeFunc(prometheus.GaugeOpts{Name: name, Help: name}, func() float64 {
+		return float64(atomic.AddInt64(&collects, 1))
+	})
+}
+
+func TestEventuallySucceedsAfterRetries(t *testing.T) {
+	ft := &fakeTB{}
+	r := NewTestRegistry(ft)
+	r.MustRegister(newCollectsGauge("collects"))
+	r.Eventually(time.Second, time.Millisecond).AssertGauge("collects", nil, 3)
+	if len(ft.failures) != 0 {
+		t.Errorf("Expected the gauge to eventually be 3 but got %q", ft.failures)
+	}
+}
+
+func TestEventuallyTimesOut(t *testing.T) {
+	ft := &fakeTB{}
+	r := NewTestRegistry(ft)
+	r.MustRegister(newFooCounter())
+	start := time.Now()
+	r.Eventually(20*time.Millisecond, time.Millisecond).AssertCount("foo", map[string]string{"code": "200"}, 2)
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("Expected the assertion to wait for the timeout but it returned after %v", elapsed)
+	}
+	assertSingleFailure(t, ft, "Expected counter [foo] value to be 2 but was 1 after waiting 20ms")
+}
+
+func TestEventuallyLintsOnce(t *testing.T) {
+	ft := &fakeTB{}
+	r := NewTestRegistry(ft, WithLint())
+	// Gauges should not have the _total suffix of counters
+	r.MustRegister(newCollectsGauge("collects_total"))
+	r.Eventually(time.Second, time.Millisecond).AssertGauge("collects_total", nil, 3)
+	assertSingleFailure(t, ft, "Found 1 lint problems")
+}
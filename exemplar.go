@@ -0,0 +1,52 @@
+package promtest
+
+import (
+	dto "github.com/prometheus/client_model/go"
+)
+
+// AssertCounterExemplar asserts that a counter in the snapshot carries an exemplar with exactly the
+// given labels, e.g. a trace_id. Exemplars are added with prometheus.ExemplarAdder.
+func (s *Snapshot) AssertCounterExemplar(name string, labels map[string]string, exemplarLabels map[string]string) {
+	s.t.Helper()
+	exemplar, ok := s.counterExemplar(name, labels)
+	if !ok {
+		return
+	}
+
+	if actualLabels := labelPairsMap(exemplar.GetLabel()); !labelsEqual(actualLabels, exemplarLabels) {
+		s.errorf(name, "Expected exemplar of counter [%s] to have the labels %v but had %v",
+			name, exemplarLabels, actualLabels)
+	}
+}
+
+// AssertCounterExemplarValue asserts that a counter in the snapshot carries an exemplar with the
+// given value, i.e. the value the counter was last incremented by with an exemplar.
+func (s *Snapshot) AssertCounterExemplarValue(name string, labels map[string]string, value float64) {
+	s.t.Helper()
+	exemplar, ok := s.counterExemplar(name, labels)
+	if !ok {
+		return
+	}
+
+	if actualValue := exemplar.GetValue(); !s.floatEquals(actualValue, value) {
+		s.errorf(name, "Expected exemplar of counter [%s] to have value %f but was %f", name, value, actualValue)
+	}
+}
+
+// counterExemplar returns the exemplar of a counter, failing the test if there is no such counter
+// or exemplar.
+func (s *Snapshot) counterExemplar(name string, labels map[string]string) (*dto.Exemplar, bool) {
+	s.t.Helper()
+	metric, err := s.findMetric(dto.MetricType_COUNTER, name, labels)
+	if err != nil {
+		s.report(name, err)
+		return nil, false
+	}
+
+	exemplar := metric.GetCounter().GetExemplar()
+	if exemplar == nil {
+		s.errorf(name, "Counter %s with the labels %v has no exemplar", name, labels)
+		return nil, false
+	}
+	return exemplar, true
+}
@@ -0,0 +1,101 @@
+package promtest
+
+import (
+	"strings"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// Expectation describes every series a snapshot is expected to contain
+type Expectation struct {
+	series []expectedSeries
+}
+
+type expectedSeries struct {
+	metricType dto.MetricType
+	name       string
+	labels     map[string]string
+	value      float64
+	sum        float64
+	count      uint64
+}
+
+// NewExpectation allocates and initializes a new, empty Expectation
+func NewExpectation() *Expectation {
+	return &Expectation{}
+}
+
+// Counter expects a counter series with the given value
+func (e *Expectation) Counter(name string, labels map[string]string, value float64) *Expectation {
+	e.series = append(e.series, expectedSeries{metricType: dto.MetricType_COUNTER, name: name, labels: labels, value: value})
+	return e
+}
+
+// Gauge expects a gauge series with the given value
+func (e *Expectation) Gauge(name string, labels map[string]string, value float64) *Expectation {
+	e.series = append(e.series, expectedSeries{metricType: dto.MetricType_GAUGE, name: name, labels: labels, value: value})
+	return e
+}
+
+// Summary expects a summary series with the given sample sum and count
+func (e *Expectation) Summary(name string, labels map[string]string, sum float64, count uint64) *Expectation {
+	e.series = append(e.series, expectedSeries{metricType: dto.MetricType_SUMMARY, name: name, labels: labels, sum: sum, count: count})
+	return e
+}
+
+// Histogram expects a histogram series with the given sample sum and count
+func (e *Expectation) Histogram(name string, labels map[string]string, sum float64, count uint64) *Expectation {
+	e.series = append(e.series, expectedSeries{metricType: dto.MetricType_HISTOGRAM, name: name, labels: labels, sum: sum, count: count})
+	return e
+}
+
+func (e *Expectation) expects(name string, labels map[string]string) bool {
+	for _, series := range e.series {
+		if series.name == name && labelsEqual(series.labels, labels) {
+			return true
+		}
+	}
+	return false
+}
+
+// AssertMatches asserts that the snapshot contains exactly the series of the expectation, with
+// their expected values. Go and process runtime metrics are ignored.
+func (s *Snapshot) AssertMatches(exp *Expectation) {
+	s.t.Helper()
+	for _, series := range exp.series {
+		if _, err := s.findMetric(series.metricType, series.name, series.labels); err != nil {
+			s.report(series.name, err)
+			continue
+		}
+		switch series.metricType {
+		case dto.MetricType_COUNTER:
+			s.report(series.name, s.CheckCount(series.name, series.labels, series.value))
+		case dto.MetricType_GAUGE:
+			s.report(series.name, s.CheckGauge(series.name, series.labels, series.value))
+		case dto.MetricType_SUMMARY:
+			s.report(series.name, s.CheckSummary(series.name, series.labels, series.sum, series.count))
+		case dto.MetricType_HISTOGRAM:
+			s.report(series.name, s.CheckHistogram(series.name, series.labels, series.sum, series.count))
+		}
+	}
+
+	for _, name := range sortedNames(s.MetricMap) {
+		if isRuntimeMetric(name) {
+			continue
+		}
+		s.markAsserted(name)
+		family := s.MetricMap[name]
+		for _, m := range family.GetMetric() {
+			if labels := labelMap(m); !exp.expects(strings.TrimPrefix(name, s.prefix), labels) {
+				s.errorf(name, "Unexpected %s %s with the labels %v", typeTitle(family.GetType()), name, labels)
+			}
+		}
+	}
+}
+
+// AssertNoMetrics asserts that the snapshot contains no series. Go and process runtime metrics are
+// ignored.
+func (s *Snapshot) AssertNoMetrics() {
+	s.t.Helper()
+	s.AssertMatches(NewExpectation())
+}
@@ -0,0 +1,285 @@
+package promtest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+// scrapeAccept prefers the protobuf format, then OpenMetrics, which unlike the text format carry
+// exemplars.
+var scrapeAccept = string(expfmt.NewFormat(expfmt.TypeProtoDelim)) + ";q=0.7," +
+	string(expfmt.NewFormat(expfmt.TypeOpenMetrics)) + ";q=0.5," +
+	string(expfmt.NewFormat(expfmt.TypeTextPlain)) + ";q=0.3"
+
+// defaultScrapeTimeout gives up on servers under test that stop responding.
+const defaultScrapeTimeout = 30 * time.Second
+
+// ScrapeOption configures how NewSnapshotFromURL scrapes a metrics endpoint
+type ScrapeOption func(o *scrapeOptions)
+
+type scrapeOptions struct {
+	client  *http.Client
+	timeout time.Duration
+}
+
+// WithScrapeClient scrapes with client, e.g. to scrape over TLS or with authentication. The
+// timeout of client is kept unless WithScrapeTimeout is given.
+func WithScrapeClient(client *http.Client) ScrapeOption {
+	return func(o *scrapeOptions) {
+		o.client = client
+	}
+}
+
+// WithScrapeTimeout gives up on a scrape after timeout instead of 30 seconds.
+func WithScrapeTimeout(timeout time.Duration) ScrapeOption {
+	return func(o *scrapeOptions) {
+		o.timeout = timeout
+	}
+}
+
+// scrapeClient returns the client scraping with the options.
+func (o scrapeOptions) scrapeClient() *http.Client {
+	c := http.Client{Timeout: defaultScrapeTimeout}
+	if o.client != nil {
+		c = *o.client
+	}
+	if o.timeout != 0 {
+		c.Timeout = o.timeout
+	}
+	return &c
+}
+
+// SnapshotFromHTTP scrapes a metrics endpoint like NewSnapshotFromURL, failing the test if it
+// cannot be scraped.
+func SnapshotFromHTTP(t TB, url string, opts ...ScrapeOption) *Snapshot {
+	t.Helper()
+	s, err := NewSnapshotFromURL(t, url, opts...)
+	if err != nil {
+		t.Fatalf("Could not scrape %s: %v", url, err)
+		return nil
+	}
+	return s
+}
+
+// SnapshotFromHandler scrapes a metrics handler like NewSnapshotFromHandler, failing the test if
+// it cannot be scraped.
+func SnapshotFromHandler(t TB, h http.Handler) *Snapshot {
+	t.Helper()
+	s, err := NewSnapshotFromHandler(t, h)
+	if err != nil {
+		t.Fatalf("Could not scrape the metrics handler: %v", err)
+		return nil
+	}
+	return s
+}
+
+// NewSnapshotFromHandler scrapes a metrics handler, such as promhttp.Handler, and creates a
+// snapshot of the metrics it exposes. Gzip compressed responses are decompressed. Exemplars are
+// kept if the handler serves the protobuf or OpenMetrics format.
+func NewSnapshotFromHandler(t TB, h http.Handler) (*Snapshot, error) {
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.Header.Set("Accept", scrapeAccept)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return newSnapshotFromResponse(t, rec.Result())
+}
+
+// NewSnapshotFromURL scrapes a metrics endpoint, e.g. of a server under test or a test container,
+// and creates a snapshot of the metrics it exposes. Scrapes time out after 30 seconds unless
+// configured otherwise by opts. Exemplars are kept if the endpoint serves the protobuf or
+// OpenMetrics format.
+func NewSnapshotFromURL(t TB, url string, opts ...ScrapeOption) (*Snapshot, error) {
+	var o scrapeOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", scrapeAccept)
+	resp, err := o.scrapeClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	return newSnapshotFromResponse(t, resp)
+}
+
+// NewSnapshotFromReader parses metrics in the Prometheus text exposition format, or the OpenMetrics
+// text format ending with # EOF, e.g. the saved output of a metrics endpoint, and creates a
+// snapshot of them. OpenMetrics exemplars are kept.
+func NewSnapshotFromReader(t TB, r io.Reader) (*Snapshot, error) {
+	families, err := decodeExposition(r)
+	if err != nil {
+		return nil, err
+	}
+	return NewSnapshotFromFamilies(t, families), nil
+}
+
+// AssertExposition asserts that the snapshot holds exactly the metric families of expected, in the
+// Prometheus text exposition format or the OpenMetrics text format ending with # EOF, including
+// their help, type and every series but not exemplars. Only the named metric families are compared
+// if metricNames are given. Differences are reported as a line diff.
+func (s *Snapshot) AssertExposition(expected string, metricNames ...string) {
+	s.t.Helper()
+	families, err := decodeExposition(strings.NewReader(expected))
+	if err != nil {
+		s.errorf("", "Could not parse the expected exposition: %v", err)
+		return
+	}
+	expectedMap := make(map[string]*dto.MetricFamily, len(families))
+	for _, family := range families {
+		expectedMap[family.GetName()] = family
+	}
+
+	actualMap := s.MetricMap
+	if len(metricNames) > 0 {
+		actualMap = s.Only(metricNames...).MetricMap
+		filtered := make(map[string]*dto.MetricFamily, len(metricNames))
+		for _, name := range metricNames {
+			if family, ok := expectedMap[s.prefix+name]; ok {
+				filtered[family.GetName()] = family
+			}
+		}
+		expectedMap = filtered
+	}
+
+	s.markAsserted(sortedNames(actualMap)...)
+	s.markAsserted(sortedNames(expectedMap)...)
+	expectedLines, actualLines := renderFamilies(expectedMap, s.floatFormat), renderFamilies(actualMap, s.floatFormat)
+	if diff := diffLines(expectedLines, actualLines); diff != "" {
+		s.errorf("", "Metrics differ from the expected exposition (-expected +actual):\n%s", diff)
+	}
+}
+
+// renderFamilies renders metric families in the text exposition format, one line per element,
+// sorted by metric name and labels. Sample values are rendered with format, if not nil.
+func renderFamilies(families map[string]*dto.MetricFamily, format func(float64) string) []string {
+	var buf strings.Builder
+	for _, name := range sortedNames(families) {
+		buf.WriteString(renderFamily(families[name], format))
+	}
+	if buf.Len() == 0 {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+}
+
+// diffLines describes how to turn the expected lines into the actual lines, prefixing removed lines
+// with "-" and added lines with "+", or returns "" if they are the same.
+func diffLines(expected, actual []string) string {
+	// common[i][j] is the length of the longest common subsequence of expected[i:] and actual[j:]
+	common := make([][]int, len(expected)+1)
+	for i := range common {
+		common[i] = make([]int, len(actual)+1)
+	}
+	for i := len(expected) - 1; i >= 0; i-- {
+		for j := len(actual) - 1; j >= 0; j-- {
+			switch {
+			case expected[i] == actual[j]:
+				common[i][j] = common[i+1][j+1] + 1
+			case common[i+1][j] >= common[i][j+1]:
+				common[i][j] = common[i+1][j]
+			default:
+				common[i][j] = common[i][j+1]
+			}
+		}
+	}
+
+	var lines []string
+	changed := false
+	i, j := 0, 0
+	for i < len(expected) || j < len(actual) {
+		switch {
+		case i < len(expected) && j < len(actual) && expected[i] == actual[j]:
+			lines = append(lines, "  "+expected[i])
+			i++
+			j++
+		case j == len(actual) || i < len(expected) && common[i+1][j] >= common[i][j+1]:
+			lines = append(lines, "- "+expected[i])
+			changed = true
+			i++
+		default:
+			lines = append(lines, "+ "+actual[j])
+			changed = true
+			j++
+		}
+	}
+	if !changed {
+		return ""
+	}
+	return strings.Join(lines, "\n")
+}
+
+func newSnapshotFromResponse(t TB, resp *http.Response) (*Snapshot, error) {
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status scraping metrics: %s", resp.Status)
+	}
+
+	body := io.Reader(resp.Body)
+	if resp.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("could not decompress metrics: %v", err)
+		}
+		defer gz.Close()
+		body = gz
+	}
+
+	var families []*dto.MetricFamily
+	var err error
+	// expfmt.ResponseFormat does not recognize OpenMetrics
+	contentType := expfmt.Format(resp.Header.Get("Content-Type"))
+	switch format := expfmt.ResponseFormat(resp.Header); {
+	case contentType.FormatType() == expfmt.TypeOpenMetrics:
+		families, err = decodeOpenMetrics(body)
+	case format == expfmt.NewFormat(expfmt.TypeUnknown):
+		families, err = decodeFamilies(body, expfmt.NewFormat(expfmt.TypeTextPlain))
+	default:
+		families, err = decodeFamilies(body, format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return NewSnapshotFromFamilies(t, families), nil
+}
+
+// decodeExposition decodes every metric family of an exposition in the OpenMetrics text format, if
+// it ends with # EOF, or else in the Prometheus text format.
+func decodeExposition(r io.Reader) ([]*dto.MetricFamily, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("could not read metrics: %v", err)
+	}
+	if isOpenMetrics(data) {
+		return decodeOpenMetrics(bytes.NewReader(data))
+	}
+	return decodeFamilies(bytes.NewReader(data), expfmt.NewFormat(expfmt.TypeTextPlain))
+}
+
+// decodeFamilies decodes every metric family of an exposition in the given format.
+func decodeFamilies(r io.Reader, format expfmt.Format) ([]*dto.MetricFamily, error) {
+	decoder := expfmt.NewDecoder(r, format)
+	var families []*dto.MetricFamily
+	for {
+		family := &dto.MetricFamily{}
+		if err := decoder.Decode(family); err == io.EOF {
+			return families, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("could not parse metrics: %v", err)
+		}
+		families = append(families, family)
+	}
+}
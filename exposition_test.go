@@ -0,0 +1,71 @@
+package promtest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+func newFooHandler() http.Handler {
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(newFooCounter())
+	return promhttp.HandlerFor(reg, promhttp.HandlerOpts{})
+}
+
+func TestSnapshotFromHTTP(t *testing.T) {
+	server := httptest.NewServer(newFooHandler())
+	defer server.Close()
+
+	ft := &fakeTB{}
+	SnapshotFromHTTP(ft, server.URL).AssertCount("foo", map[string]string{"code": "200"}, 1)
+	SnapshotFromHTTP(ft, server.URL, WithScrapeClient(server.Client()), WithScrapeTimeout(time.Second)).
+		AssertCount("foo", map[string]string{"code": "200"}, 1)
+	SnapshotFromHandler(ft, newFooHandler()).AssertCount("foo", map[string]string{"code": "200"}, 1)
+	if len(ft.failures) != 0 {
+		t.Errorf("Expected no failure but got %q", ft.failures)
+	}
+}
+
+func TestSnapshotFromHTTPFailures(t *testing.T) {
+	unavailable := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	})
+	stopped := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		<-stopped
+	}))
+	defer slow.Close()
+	defer close(stopped)
+
+	tests := []struct {
+		name    string
+		scrape  func(ft *fakeTB) *Snapshot
+		failure string
+	}{
+		{
+			name:    "SnapshotFromHandler of an unavailable handler",
+			scrape:  func(ft *fakeTB) *Snapshot { return SnapshotFromHandler(ft, unavailable) },
+			failure: "Could not scrape the metrics handler: unexpected status scraping metrics: 503 Service Unavailable",
+		},
+		{
+			name: "SnapshotFromHTTP with a timeout",
+			scrape: func(ft *fakeTB) *Snapshot {
+				return SnapshotFromHTTP(ft, slow.URL, WithScrapeTimeout(10*time.Millisecond))
+			},
+			failure: "Client.Timeout exceeded",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ft := &fakeTB{}
+			if s := tt.scrape(ft); s != nil {
+				t.Errorf("Expected no snapshot but got %v", s)
+			}
+			assertSingleFailure(t, ft, tt.failure)
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package promtest
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// SnapshotOption rewrites the metrics of a snapshot when it is taken, e.g. to drop labels injected
+// by shared middleware that depend on the environment.
+type SnapshotOption func(o *snapshotOptions)
+
+type snapshotOptions struct {
+	droppedLabels []string
+	keptMetrics   []string
+	renamedLabels map[string]string
+}
+
+// DropLabels removes the named labels from every series of the snapshot.
+func DropLabels(names ...string) SnapshotOption {
+	return func(o *snapshotOptions) {
+		o.droppedLabels = append(o.droppedLabels, names...)
+	}
+}
+
+// KeepMetrics keeps only the metric families whose name fully matches one of the regular
+// expressions, e.g. "myapp_.*".
+func KeepMetrics(patterns ...string) SnapshotOption {
+	return func(o *snapshotOptions) {
+		o.keptMetrics = append(o.keptMetrics, patterns...)
+	}
+}
+
+// RenameLabel renames the label called from to to in every series of the snapshot. Taking the
+// snapshot fails if a series keeps another label called to.
+func RenameLabel(from, to string) SnapshotOption {
+	return func(o *snapshotOptions) {
+		if o.renamedLabels == nil {
+			o.renamedLabels = make(map[string]string)
+		}
+		o.renamedLabels[from] = to
+	}
+}
+
+// WithSnapshotOptions applies the snapshot options to every snapshot taken from the registry,
+// before the options passed to TakeSnapshot.
+func WithSnapshotOptions(opts ...SnapshotOption) Option {
+	return func(r *TestRegistry) {
+		r.snapshotOpts = append(r.snapshotOpts, opts...)
+	}
+}
+
+// rewrite rewrites the metric families of a snapshot of the registry by its snapshot options and
+// opts, if any.
+func (r *TestRegistry) rewrite(metricMap map[string]*dto.MetricFamily,
+	opts []SnapshotOption) (map[string]*dto.MetricFamily, error) {
+	opts = append(append([]SnapshotOption(nil), r.snapshotOpts...), opts...)
+	if len(opts) == 0 {
+		return metricMap, nil
+	}
+	var o snapshotOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o.rewrite(metricMap)
+}
+
+// rewrite returns copies of the metric families rewritten by the options. Series whose labels
+// collide once rewritten are reported as an error.
+func (o *snapshotOptions) rewrite(metricMap map[string]*dto.MetricFamily) (map[string]*dto.MetricFamily, error) {
+	kept := make([]*regexp.Regexp, 0, len(o.keptMetrics))
+	for _, pattern := range o.keptMetrics {
+		re, err := regexp.Compile("^(?:" + pattern + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid metric pattern %q: %v", pattern, err)
+		}
+		kept = append(kept, re)
+	}
+
+	rewritten := make(map[string]*dto.MetricFamily, len(metricMap))
+	for name, family := range metricMap {
+		if len(kept) > 0 && !matchesAny(kept, name) {
+			continue
+		}
+		c := &dto.MetricFamily{
+			Name:   family.Name,
+			Help:   family.Help,
+			Type:   family.Type,
+			Unit:   family.Unit,
+			Metric: make([]*dto.Metric, 0, len(family.GetMetric())),
+		}
+		seen := make(map[string]bool, len(family.GetMetric()))
+		for _, m := range family.GetMetric() {
+			labels, err := o.rewriteLabels(labelMap(m))
+			if err != nil {
+				return nil, fmt.Errorf("rewriting the labels of %s%s: %v", name, formatLabels(labelMap(m)), err)
+			}
+			key := formatLabels(labels)
+			if seen[key] {
+				return nil, fmt.Errorf("rewriting the labels of %s makes several series collide as %s%s", name, name, key)
+			}
+			seen[key] = true
+			c.Metric = append(c.Metric, &dto.Metric{
+				Label:       labelPairs(labels),
+				Gauge:       m.Gauge,
+				Counter:     m.Counter,
+				Summary:     m.Summary,
+				Untyped:     m.Untyped,
+				Histogram:   m.Histogram,
+				TimestampMs: m.TimestampMs,
+			})
+		}
+		rewritten[name] = c
+	}
+	return rewritten, nil
+}
+
+// rewriteLabels drops and renames the labels of a series. Renaming a label to the name of another
+// label the series keeps is reported as an error rather than overwriting either value.
+func (o *snapshotOptions) rewriteLabels(labels map[string]string) (map[string]string, error) {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	rewritten := make(map[string]string, len(labels))
+	renamedFrom := make(map[string]string, len(labels))
+	for _, name := range names {
+		if containsString(o.droppedLabels, name) {
+			continue
+		}
+		to := name
+		if renamed, ok := o.renamedLabels[name]; ok {
+			to = renamed
+		}
+		if from, ok := renamedFrom[to]; ok {
+			return nil, fmt.Errorf("the labels %s and %s would both be called %s", from, name, to)
+		}
+		renamedFrom[to] = name
+		rewritten[to] = labels[name]
+	}
+	return rewritten, nil
+}
+
+func matchesAny(patterns []*regexp.Regexp, name string) bool {
+	for _, re := range patterns {
+		if re.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
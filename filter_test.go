@@ -0,0 +1,60 @@
+package promtest
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestRenameLabelCollision(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []SnapshotOption
+		// labels of the rewritten series, or nil if the renamed labels collide
+		labels map[string]string
+	}{
+		{
+			name: "onto a kept label",
+			opts: []SnapshotOption{RenameLabel("env", "environment")},
+		},
+		{
+			name: "two labels onto one",
+			opts: []SnapshotOption{RenameLabel("env", "stage"), RenameLabel("environment", "stage")},
+		},
+		{
+			name:   "onto a dropped label",
+			opts:   []SnapshotOption{DropLabels("environment"), RenameLabel("env", "environment")},
+			labels: map[string]string{"environment": "a"},
+		},
+		{
+			name:   "swapped labels",
+			opts:   []SnapshotOption{RenameLabel("env", "environment"), RenameLabel("environment", "env")},
+			labels: map[string]string{"env": "b", "environment": "a"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ft := &fakeTB{}
+			r := NewTestRegistry(ft)
+			g := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "foo", Help: "foo"}, []string{"env", "environment"})
+			r.MustRegister(g)
+			g.WithLabelValues("a", "b").Set(1)
+
+			s, err := r.TakeSnapshot(tt.opts...)
+			if tt.labels == nil {
+				if err == nil || !strings.Contains(err.Error(), "would both be called") {
+					t.Errorf("Expected the renamed labels to collide but got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Could not take a snapshot: %v", err)
+			}
+			s.AssertGauge("foo", tt.labels, 1)
+			if len(ft.failures) != 0 {
+				t.Errorf("Expected no failure but got %q", ft.failures)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package promtest
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// UpdateGolden makes AssertGolden rewrite golden files instead of comparing with them. It defaults
+// to true when the PROMTEST_UPDATE_GOLDEN environment variable is 1, and can be bound to a flag of
+// the test package, e.g.
+//
+//	flag.BoolVar(&promtest.UpdateGolden, "update", promtest.UpdateGolden, "rewrite golden files")
+var UpdateGolden = os.Getenv("PROMTEST_UPDATE_GOLDEN") == "1"
+
+// GoldenOption configures how AssertGolden serializes a snapshot
+type GoldenOption func(o *goldenOptions)
+
+type goldenOptions struct {
+	excludedMetrics   []string
+	excludedLabels    []string
+	excludeRuntime    bool
+	excludeTimestamps bool
+}
+
+// ExcludeMetrics leaves the named metric families out of the golden file.
+func ExcludeMetrics(names ...string) GoldenOption {
+	return func(o *goldenOptions) {
+		o.excludedMetrics = append(o.excludedMetrics, names...)
+	}
+}
+
+// ExcludeRuntimeMetrics leaves the go_* and process_* metric families out of the golden file.
+func ExcludeRuntimeMetrics() GoldenOption {
+	return func(o *goldenOptions) {
+		o.excludeRuntime = true
+	}
+}
+
+// ExcludeLabels removes the named labels, e.g. a hostname, from every series in the golden file.
+func ExcludeLabels(names ...string) GoldenOption {
+	return func(o *goldenOptions) {
+		o.excludedLabels = append(o.excludedLabels, names...)
+	}
+}
+
+// ExcludeTimestamps removes the timestamps of the series in the golden file.
+func ExcludeTimestamps() GoldenOption {
+	return func(o *goldenOptions) {
+		o.excludeTimestamps = true
+	}
+}
+
+// AssertGolden asserts that the snapshot, serialized in the text exposition format, matches the
+// golden file at path. The golden file is rewritten instead if UpdateGolden is set.
+func (s *Snapshot) AssertGolden(path string, opts ...GoldenOption) {
+	s.t.Helper()
+	var o goldenOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	filtered := o.filter(s.MetricMap)
+	s.markAsserted(sortedNames(filtered)...)
+	lines := renderFamilies(filtered, s.floatFormat)
+	actual := strings.Join(lines, "\n") + "\n"
+
+	if UpdateGolden {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			s.errorf("", "Could not create the directory of golden file %s: %v", path, err)
+			return
+		}
+		if err := os.WriteFile(path, []byte(actual), 0644); err != nil {
+			s.errorf("", "Could not write golden file %s: %v", path, err)
+		}
+		return
+	}
+
+	expected, err := os.ReadFile(path)
+	if err != nil {
+		s.errorf("", "Could not read golden file %s, run the tests with PROMTEST_UPDATE_GOLDEN=1 to create it: %v",
+			path, err)
+		return
+	}
+	if string(expected) == actual {
+		return
+	}
+	expectedLines := strings.Split(strings.TrimSuffix(string(expected), "\n"), "\n")
+	s.errorf("", "Metrics differ from golden file %s, run the tests with PROMTEST_UPDATE_GOLDEN=1 to rewrite it "+
+		"(-golden +actual):\n%s", path, diffLines(expectedLines, lines))
+}
+
+// filter returns copies of the metric families without the excluded families, labels and
+// timestamps.
+func (o *goldenOptions) filter(metricMap map[string]*dto.MetricFamily) map[string]*dto.MetricFamily {
+	filtered := make(map[string]*dto.MetricFamily, len(metricMap))
+	for name, family := range metricMap {
+		if containsString(o.excludedMetrics, name) || o.excludeRuntime && isRuntimeMetric(name) {
+			continue
+		}
+		c := &dto.MetricFamily{
+			Name:   family.Name,
+			Help:   family.Help,
+			Type:   family.Type,
+			Unit:   family.Unit,
+			Metric: make([]*dto.Metric, 0, len(family.GetMetric())),
+		}
+		for _, m := range family.GetMetric() {
+			filteredMetric := &dto.Metric{
+				Gauge:       m.Gauge,
+				Counter:     m.Counter,
+				Summary:     m.Summary,
+				Untyped:     m.Untyped,
+				Histogram:   m.Histogram,
+				TimestampMs: m.TimestampMs,
+			}
+			if o.excludeTimestamps {
+				filteredMetric.TimestampMs = nil
+			}
+			for _, labelPair := range m.GetLabel() {
+				if !containsString(o.excludedLabels, labelPair.GetName()) {
+					filteredMetric.Label = append(filteredMetric.Label, labelPair)
+				}
+			}
+			c.Metric = append(c.Metric, filteredMetric)
+		}
+		filtered[name] = c
+	}
+	return filtered
+}
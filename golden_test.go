@@ -0,0 +1,125 @@
+package promtest
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const fooGolden = `# HELP foo foo
+# TYPE foo counter
+foo{code="200"} 1
+`
+
+// setUpdateGolden sets UpdateGolden for the rest of the test.
+func setUpdateGolden(t *testing.T, update bool) {
+	previous := UpdateGolden
+	UpdateGolden = update
+	t.Cleanup(func() { UpdateGolden = previous })
+}
+
+func TestAssertGolden(t *testing.T) {
+	setUpdateGolden(t, false)
+	ft := &fakeTB{}
+	newSnapshotOf(t, ft, newFooCounter()).AssertGolden(filepath.Join("testdata", "foo.golden"))
+	if len(ft.failures) != 0 {
+		t.Errorf("Expected the snapshot to match the golden file but got %q", ft.failures)
+	}
+}
+
+func TestAssertGoldenMismatch(t *testing.T) {
+	setUpdateGolden(t, false)
+	ft := &fakeTB{}
+	c := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "foo", Help: "foo"}, []string{"code"})
+	c.WithLabelValues("200").Add(2)
+	c.WithLabelValues("500").Inc()
+	newSnapshotOf(t, ft, c).AssertGolden(filepath.Join("testdata", "foo.golden"))
+	assertSingleFailure(t, ft, `Metrics differ from golden file testdata/foo.golden, run the tests with PROMTEST_UPDATE_GOLDEN=1 to rewrite it (-golden +actual):
+  # HELP foo foo
+  # TYPE foo counter
+- foo{code="200"} 1
++ foo{code="200"} 2
++ foo{code="500"} 1`)
+}
+
+func TestAssertGoldenMissingFile(t *testing.T) {
+	setUpdateGolden(t, false)
+	ft := &fakeTB{}
+	newSnapshotOf(t, ft, newFooCounter()).AssertGolden(filepath.Join(t.TempDir(), "missing.golden"))
+	assertSingleFailure(t, ft, "run the tests with PROMTEST_UPDATE_GOLDEN=1 to create it")
+}
+
+func TestAssertGoldenUpdate(t *testing.T) {
+	setUpdateGolden(t, true)
+	path := filepath.Join(t.TempDir(), "golden", "foo.golden")
+	ft := &fakeTB{}
+	newSnapshotOf(t, ft, newFooCounter()).AssertGolden(path)
+	if len(ft.failures) != 0 {
+		t.Fatalf("Expected the golden file to be written but got %q", ft.failures)
+	}
+	if golden, err := os.ReadFile(path); err != nil || string(golden) != fooGolden {
+		t.Fatalf("Expected the golden file to be %q but was %q (%v)", fooGolden, golden, err)
+	}
+
+	UpdateGolden = false
+	newSnapshotOf(t, ft, newFooCounter()).AssertGolden(path)
+	if len(ft.failures) != 0 {
+		t.Errorf("Expected the snapshot to match the rewritten golden file but got %q", ft.failures)
+	}
+}
+
+// TestAssertGoldenUpdateFromEnvironment runs the test binary again with PROMTEST_UPDATE_GOLDEN=1,
+// which is read when the package is initialized.
+func TestAssertGoldenUpdateFromEnvironment(t *testing.T) {
+	if path := os.Getenv("PROMTEST_TEST_GOLDEN_PATH"); path != "" {
+		newSnapshotOf(t, &fakeTB{}, newFooCounter()).AssertGolden(path, ExcludeLabels("code"))
+		return
+	}
+
+	path := filepath.Join(t.TempDir(), "foo.golden")
+	cmd := exec.Command(os.Args[0], "-test.run=^TestAssertGoldenUpdateFromEnvironment$")
+	cmd.Env = append(os.Environ(), "PROMTEST_UPDATE_GOLDEN=1", "PROMTEST_TEST_GOLDEN_PATH="+path)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("Could not run the test with PROMTEST_UPDATE_GOLDEN=1: %v\n%s", err, out)
+	}
+	expected := "# HELP foo foo\n# TYPE foo counter\nfoo 1\n"
+	if golden, err := os.ReadFile(path); err != nil || string(golden) != expected {
+		t.Errorf("Expected the golden file to be %q but was %q (%v)", expected, golden, err)
+	}
+}
+
+func TestAssertGoldenOptions(t *testing.T) {
+	setUpdateGolden(t, false)
+	desc := prometheus.NewDesc("bar", "bar", []string{"host"}, nil)
+	collector := constCollector{prometheus.NewMetricWithTimestamp(time.Unix(1, 0),
+		prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, 2, "host-1"))}
+	path := filepath.Join(t.TempDir(), "foo.golden")
+	if err := os.WriteFile(path, []byte(fooGolden), 0644); err != nil {
+		t.Fatalf("Could not write the golden file: %v", err)
+	}
+
+	ft := &fakeTB{}
+	r := NewTestRegistryWithDefaults(ft)
+	r.MustRegister(newFooCounter(), collector)
+	s, err := r.TakeSnapshot()
+	if err != nil {
+		t.Fatalf("Could not take a snapshot: %v", err)
+	}
+	s.AssertGolden(path, ExcludeRuntimeMetrics(), ExcludeMetrics("bar"))
+	if len(ft.failures) != 0 {
+		t.Fatalf("Expected the excluded metrics to be left out but got %q", ft.failures)
+	}
+
+	expected := "# HELP bar bar\n# TYPE bar gauge\nbar 2\n" + fooGolden
+	if err := os.WriteFile(path, []byte(expected), 0644); err != nil {
+		t.Fatalf("Could not write the golden file: %v", err)
+	}
+	s.AssertGolden(path, ExcludeRuntimeMetrics(), ExcludeLabels("host"), ExcludeTimestamps())
+	if len(ft.failures) != 0 {
+		t.Errorf("Expected the excluded labels and timestamps to be left out but got %q", ft.failures)
+	}
+}
@@ -0,0 +1,178 @@
+package promtest
+
+import (
+	"errors"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// AssertCounterVec asserts existence and count of the series of a counter vector in the snapshot,
+// taking the name and constant labels of the series from the vector instead of a string.
+func (s *Snapshot) AssertCounterVec(cv *prometheus.CounterVec, labels prometheus.Labels, value float64,
+	cmp ...Comparison) {
+	s.t.Helper()
+	if name, seriesLabels, ok := s.collectorSeries(cv, labels); ok {
+		s.AssertCount(name, seriesLabels, value, cmp...)
+	}
+}
+
+// AssertCounterCollector asserts existence and count of a counter in the snapshot, taking its name
+// and constant labels from the counter instead of a string.
+func (s *Snapshot) AssertCounterCollector(c prometheus.Counter, value float64, cmp ...Comparison) {
+	s.t.Helper()
+	if name, seriesLabels, ok := s.collectorSeries(c, nil); ok {
+		s.AssertCount(name, seriesLabels, value, cmp...)
+	}
+}
+
+// AssertGaugeVec asserts existence and value of the series of a gauge vector in the snapshot,
+// taking the name and constant labels of the series from the vector instead of a string.
+func (s *Snapshot) AssertGaugeVec(gv *prometheus.GaugeVec, labels prometheus.Labels, value float64,
+	cmp ...Comparison) {
+	s.t.Helper()
+	if name, seriesLabels, ok := s.collectorSeries(gv, labels); ok {
+		s.AssertGauge(name, seriesLabels, value, cmp...)
+	}
+}
+
+// AssertGaugeCollector asserts existence and value of a gauge in the snapshot, taking its name and
+// constant labels from the gauge instead of a string.
+func (s *Snapshot) AssertGaugeCollector(g prometheus.Gauge, value float64, cmp ...Comparison) {
+	s.t.Helper()
+	if name, seriesLabels, ok := s.collectorSeries(g, nil); ok {
+		s.AssertGauge(name, seriesLabels, value, cmp...)
+	}
+}
+
+// AssertSummaryVec asserts the existence and the sample sum and count of the series of a summary
+// vector in the snapshot, taking the name and constant labels of the series from the vector.
+func (s *Snapshot) AssertSummaryVec(sv *prometheus.SummaryVec, labels prometheus.Labels, sum float64, count uint64,
+	cmp ...Comparison) {
+	s.t.Helper()
+	if name, seriesLabels, ok := s.collectorSeries(sv, labels); ok {
+		s.AssertSummary(name, seriesLabels, sum, count, cmp...)
+	}
+}
+
+// AssertSummaryCollector asserts the existence and the sample sum and count of a summary in the
+// snapshot, taking its name and constant labels from the summary.
+func (s *Snapshot) AssertSummaryCollector(sm prometheus.Summary, sum float64, count uint64, cmp ...Comparison) {
+	s.t.Helper()
+	if name, seriesLabels, ok := s.collectorSeries(sm, nil); ok {
+		s.AssertSummary(name, seriesLabels, sum, count, cmp...)
+	}
+}
+
+// AssertHistogramVec asserts the existence and the sample sum and count of the series of a
+// histogram vector in the snapshot, taking the name and constant labels of the series from the
+// vector.
+func (s *Snapshot) AssertHistogramVec(hv *prometheus.HistogramVec, labels prometheus.Labels, sum float64, count uint64,
+	cmp ...Comparison) {
+	s.t.Helper()
+	if name, seriesLabels, ok := s.collectorSeries(hv, labels); ok {
+		s.AssertHistogram(name, seriesLabels, sum, count, cmp...)
+	}
+}
+
+// AssertHistogramCollector asserts the existence and the sample sum and count of a histogram in
+// the snapshot, taking its name and constant labels from the histogram.
+func (s *Snapshot) AssertHistogramCollector(h prometheus.Histogram, sum float64, count uint64, cmp ...Comparison) {
+	s.t.Helper()
+	if name, seriesLabels, ok := s.collectorSeries(h, nil); ok {
+		s.AssertHistogram(name, seriesLabels, sum, count, cmp...)
+	}
+}
+
+// collectorSeries returns the name of the single metric described by a collector, and labels with
+// its constant labels added. A collector that cannot be described fails the test.
+func (s *Snapshot) collectorSeries(c prometheus.Collector, labels prometheus.Labels) (string, map[string]string, bool) {
+	s.t.Helper()
+	descs := make(chan *prometheus.Desc)
+	go func() {
+		c.Describe(descs)
+		close(descs)
+	}()
+	var described []*prometheus.Desc
+	for desc := range descs {
+		described = append(described, desc)
+	}
+	if len(described) != 1 {
+		s.errorf("", "Expected the collector to describe a single metric but it describes %d", len(described))
+		return "", nil, false
+	}
+
+	name, constLabels, err := gatherDesc(described[0])
+	if err != nil {
+		s.errorf("", "Could not read the name of %s: %v", described[0], err)
+		return "", nil, false
+	}
+	seriesLabels := make(map[string]string, len(constLabels)+len(labels))
+	for labelName, value := range constLabels {
+		seriesLabels[labelName] = value
+	}
+	for labelName, value := range labels {
+		seriesLabels[labelName] = value
+	}
+	return name, seriesLabels, true
+}
+
+// maxVariableLabels is the most variable labels of a metric gatherDesc supports.
+const maxVariableLabels = 64
+
+// probeLabelValue is the value of the variable labels of the series gathered by gatherDesc, telling
+// them apart from its constant labels.
+const probeLabelValue = "\x00promtest"
+
+// gatherDesc returns the fully-qualified name and the constant labels of a metric, which its
+// prometheus.Desc does not expose, by gathering a series of the metric from a throwaway pedantic
+// registry. The collector of the metric is not collected, so it works for empty vectors too.
+func gatherDesc(desc *prometheus.Desc) (string, map[string]string, error) {
+	var metric prometheus.Metric
+	var err error
+	// The number of variable labels is only known by trying
+	for n := 0; n <= maxVariableLabels; n++ {
+		values := make([]string, n)
+		for i := range values {
+			values[i] = probeLabelValue
+		}
+		if metric, err = prometheus.NewConstMetric(desc, prometheus.UntypedValue, 0, values...); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return "", nil, err
+	}
+
+	reg := prometheus.NewPedanticRegistry()
+	if err := reg.Register(&probeCollector{desc, metric}); err != nil {
+		return "", nil, err
+	}
+	families, err := reg.Gather()
+	if err != nil {
+		return "", nil, err
+	}
+	if len(families) != 1 || len(families[0].GetMetric()) != 1 {
+		return "", nil, errors.New("the probed series was not gathered")
+	}
+	constLabels := make(map[string]string)
+	for _, labelPair := range families[0].GetMetric()[0].GetLabel() {
+		if labelPair.GetValue() != probeLabelValue {
+			constLabels[labelPair.GetName()] = labelPair.GetValue()
+		}
+	}
+	return families[0].GetName(), constLabels, nil
+}
+
+// probeCollector collects the single series gathered by gatherDesc.
+type probeCollector struct {
+	desc   *prometheus.Desc
+	metric prometheus.Metric
+}
+
+func (c *probeCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *probeCollector) Collect(ch chan<- prometheus.Metric) {
+	ch <- c.metric
+}
@@ -0,0 +1,56 @@
+package promtest
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestCollectorAssertions(t *testing.T) {
+	constLabels := prometheus.Labels{"service": "api"}
+	counter := prometheus.NewCounter(prometheus.CounterOpts{Namespace: "app", Name: "starts_total", Help: "h",
+		ConstLabels: constLabels})
+	counterVec := prometheus.NewCounterVec(prometheus.CounterOpts{Namespace: "app", Subsystem: "http",
+		Name: "requests_total", Help: "h", ConstLabels: constLabels}, []string{"code", "method"})
+	emptyVec := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "queue_length", Help: "h",
+		ConstLabels: prometheus.Labels{"quote": `a "b"`}}, []string{"queue"})
+	histogramVec := prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "latency_seconds", Help: "h",
+		Buckets: []float64{1}}, []string{"code"})
+
+	ft := &fakeTB{}
+	r := NewTestRegistry(ft)
+	r.MustRegister(counter, counterVec, emptyVec, histogramVec)
+	counter.Add(2)
+	counterVec.WithLabelValues("200", "GET").Add(3)
+	histogramVec.WithLabelValues("200").Observe(0.5)
+	s, err := r.TakeSnapshot()
+	if err != nil {
+		t.Fatalf("Could not take a snapshot: %v", err)
+	}
+
+	s.AssertCounterCollector(counter, 2)
+	s.AssertCounterVec(counterVec, prometheus.Labels{"code": "200", "method": "GET"}, 3)
+	s.AssertGaugeVec(emptyVec, prometheus.Labels{"queue": "default"}, 0)
+	s.AssertHistogramVec(histogramVec, prometheus.Labels{"code": "200"}, 0.5, 1)
+	if len(ft.failures) != 0 {
+		t.Errorf("Expected no failure but got %q", ft.failures)
+	}
+
+	s.AssertCounterVec(counterVec, prometheus.Labels{"code": "200", "method": "GET"}, 4)
+	assertSingleFailure(t, ft, "app_http_requests_total")
+}
+
+func TestGatherDesc(t *testing.T) {
+	desc := prometheus.NewDesc("app_requests_total", "h", []string{"code"}, prometheus.Labels{"service": "api"})
+	name, constLabels, err := gatherDesc(desc)
+	if err != nil {
+		t.Fatalf("Could not gather the desc: %v", err)
+	}
+	if name != "app_requests_total" || !labelsEqual(constLabels, map[string]string{"service": "api"}) {
+		t.Errorf("Expected app_requests_total with the labels {service=\"api\"} but was %s%s", name, formatLabels(constLabels))
+	}
+
+	if _, _, err := gatherDesc(prometheus.NewDesc("foo", "h", []string{"code", "code"}, nil)); err == nil {
+		t.Error("Expected an error gathering an invalid desc")
+	}
+}
@@ -0,0 +1,471 @@
+package promtest
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// AssertHistogramBucket asserts the cumulative count of the bucket with the upper bound le of a
+// histogram in the snapshot, i.e. the number of observations <= le, like AssertHistogramBucketCount.
+func (s *Snapshot) AssertHistogramBucket(name string, labels map[string]string, le float64, cumulativeCount uint64) {
+	s.t.Helper()
+	metric, err := s.findMetric(dto.MetricType_HISTOGRAM, name, labels)
+	if err != nil {
+		if cumulativeCount == 0 && isNotFound(err) {
+			// Histogram not existing is the same as the histogram having 0 value
+			return
+		}
+		s.report(name, err)
+		return
+	}
+
+	histogram := metric.GetHistogram()
+	bucket := findBucket(histogram, le)
+	if bucket == nil {
+		s.reportMissingBucket(name, histogram, le)
+		return
+	}
+	if actualCount := bucket.GetCumulativeCount(); actualCount != cumulativeCount {
+		s.errorf(name, "Expected %d observations of histogram [%s] to be <= %f but was %d",
+			cumulativeCount, name, le, actualCount)
+	}
+}
+
+// AssertHistogramBuckets asserts both the upper bounds of the buckets of a histogram in the
+// snapshot and their cumulative counts. The +Inf bucket may be left out of buckets.
+func (s *Snapshot) AssertHistogramBuckets(name string, labels map[string]string, buckets map[float64]uint64) {
+	s.t.Helper()
+	metric, err := s.findMetric(dto.MetricType_HISTOGRAM, name, labels)
+	if err != nil {
+		if isNotFound(err) && allZero(buckets) {
+			// Histogram not existing is the same as the histogram having 0 value
+			return
+		}
+		s.report(name, err)
+		return
+	}
+
+	histogram := metric.GetHistogram()
+	expected := &dto.Histogram{}
+	for le, count := range buckets {
+		le, count := le, count
+		expected.Bucket = append(expected.Bucket, &dto.Bucket{UpperBound: &le, CumulativeCount: &count})
+	}
+	actual := &dto.Histogram{Bucket: sortedBuckets(histogram)}
+	if n := len(actual.Bucket); n > 0 && math.IsInf(actual.Bucket[n-1].GetUpperBound(), 1) {
+		actual.Bucket = actual.Bucket[:n-1]
+	}
+	if _, ok := buckets[math.Inf(1)]; ok {
+		actual.Bucket = append(actual.Bucket, findBucket(histogram, math.Inf(1)))
+	}
+	actualBuckets, expectedBuckets := actual.GetBucket(), sortedBuckets(expected)
+	if len(actualBuckets) != len(expectedBuckets) {
+		s.errorf(name, "Expected histogram [%s] upper bounds to be %s but were %s",
+			name, formatUpperBounds(expected), formatUpperBounds(actual))
+		return
+	}
+	for i, bucket := range expectedBuckets {
+		if !floatEquals(actualBuckets[i].GetUpperBound(), bucket.GetUpperBound(), defaultTolerance) {
+			s.errorf(name, "Expected histogram [%s] upper bounds to be %s but were %s",
+				name, formatUpperBounds(expected), formatUpperBounds(actual))
+			return
+		}
+	}
+
+	for i, bucket := range expectedBuckets {
+		if actualCount := actualBuckets[i].GetCumulativeCount(); actualCount != bucket.GetCumulativeCount() {
+			s.errorf(name, "Expected %d observations of histogram [%s] to be <= %f but was %d",
+				bucket.GetCumulativeCount(), name, bucket.GetUpperBound(), actualCount)
+		}
+	}
+}
+
+// AssertHistogramBucketCount asserts the cumulative count of the bucket with the given upper bound
+// of a histogram in the snapshot, i.e. the number of observations <= upperBound, as
+// AssertHistogramBuckets does for several buckets. AssertHistogramBandCount asserts the
+// observations of a single band instead.
+func (s *Snapshot) AssertHistogramBucketCount(name string, labels map[string]string, upperBound float64, count uint64) {
+	s.t.Helper()
+	s.AssertHistogramBucket(name, labels, upperBound, count)
+}
+
+// AssertHistogramBandCount asserts the number of observations of a histogram in the snapshot that
+// fell into the band of the bucket with the given upper bound, i.e. above the upper bound of the
+// previous bucket, as AssertHistogramPerBucketCounts does for several bands.
+// AssertHistogramBucketCount asserts the cumulative count of a bucket instead.
+func (s *Snapshot) AssertHistogramBandCount(name string, labels map[string]string, upperBound float64, count uint64) {
+	s.t.Helper()
+	s.AssertHistogramPerBucketCounts(name, labels, map[float64]uint64{upperBound: count})
+}
+
+// AssertHistogramSLO asserts that at least minFraction of the observations of a histogram in the
+// snapshot fell into the bucket with the upper bound le, e.g. that 99% of requests completed
+// within 300ms.
+func (s *Snapshot) AssertHistogramSLO(name string, labels map[string]string, le float64, minFraction float64) {
+	s.t.Helper()
+	metric, err := s.findMetric(dto.MetricType_HISTOGRAM, name, labels)
+	if err != nil {
+		s.report(name, err)
+		return
+	}
+
+	histogram := metric.GetHistogram()
+	bucket := findBucket(histogram, le)
+	if bucket == nil {
+		s.reportMissingBucket(name, histogram, le)
+		return
+	}
+	totalCount := histogram.GetSampleCount()
+	if totalCount == 0 {
+		s.errorf(name, "Histogram %s has no observations", name)
+		return
+	}
+
+	if fraction := float64(bucket.GetCumulativeCount()) / float64(totalCount); fraction < minFraction {
+		s.errorf(name, "Expected at least %f of histogram [%s] observations to be <= %f but was %f",
+			minFraction, name, le, fraction)
+	}
+}
+
+// AssertHistogramMean asserts that the mean of the observations of a histogram in the snapshot,
+// its sample sum divided by its sample count, is within tolerance of mean.
+func (s *Snapshot) AssertHistogramMean(name string, labels map[string]string, mean float64, tolerance float64) {
+	s.t.Helper()
+	metric, err := s.findMetric(dto.MetricType_HISTOGRAM, name, labels)
+	if err != nil {
+		s.report(name, err)
+		return
+	}
+
+	histogram := metric.GetHistogram()
+	if histogram.GetSampleCount() == 0 {
+		s.errorf(name, "Histogram %s has no observations", name)
+		return
+	}
+	if actualMean := histogram.GetSampleSum() / float64(histogram.GetSampleCount()); math.Abs(actualMean-mean) > tolerance {
+		s.errorf(name, "Expected the mean of histogram [%s] observations to be within %f of %f but was %f",
+			name, tolerance, mean, actualMean)
+	}
+}
+
+// AssertHistogramQuantileLE asserts that the q quantile of a histogram in the snapshot, estimated
+// from its buckets like the histogram_quantile function of PromQL, is at most upperBound, e.g. that
+// the 0.99 quantile of request latencies is under 250ms.
+func (s *Snapshot) AssertHistogramQuantileLE(name string, labels map[string]string, q float64, upperBound float64) {
+	s.t.Helper()
+	metric, err := s.findMetric(dto.MetricType_HISTOGRAM, name, labels)
+	if err != nil {
+		s.report(name, err)
+		return
+	}
+
+	histogram := metric.GetHistogram()
+	if len(histogram.GetBucket()) == 0 {
+		s.reportMissingBucket(name, histogram, upperBound)
+		return
+	}
+	if histogram.GetSampleCount() == 0 {
+		s.errorf(name, "Histogram %s has no observations", name)
+		return
+	}
+	if quantile := histogramQuantile(q, histogram); !(quantile <= upperBound) {
+		s.errorf(name, "Expected the %f quantile of histogram [%s] to be <= %f but was %f",
+			q, name, upperBound, quantile)
+	}
+}
+
+// histogramQuantile estimates the q quantile of a histogram like the histogram_quantile function
+// of PromQL, interpolating linearly within the bucket holding the quantile. The quantile is the
+// upper bound of the highest finite bucket if it falls into the +Inf bucket.
+func histogramQuantile(q float64, histogram *dto.Histogram) float64 {
+	switch {
+	case math.IsNaN(q):
+		return math.NaN()
+	case q < 0:
+		return math.Inf(-1)
+	case q > 1:
+		return math.Inf(1)
+	}
+	buckets := sortedBuckets(histogram)
+	if n := len(buckets); n == 0 || !math.IsInf(buckets[n-1].GetUpperBound(), 1) {
+		buckets = append(buckets, findBucket(histogram, math.Inf(1)))
+	}
+	if len(buckets) < 2 {
+		return math.NaN()
+	}
+	last := len(buckets) - 1
+	observations := float64(buckets[last].GetCumulativeCount())
+	if observations == 0 {
+		return math.NaN()
+	}
+
+	rank := q * observations
+	b := sort.Search(last, func(i int) bool {
+		return float64(buckets[i].GetCumulativeCount()) >= rank
+	})
+	if b == last {
+		return buckets[last-1].GetUpperBound()
+	}
+	if b == 0 && buckets[0].GetUpperBound() <= 0 {
+		return buckets[0].GetUpperBound()
+	}
+	bucketStart := 0.0
+	bucketEnd := buckets[b].GetUpperBound()
+	count := float64(buckets[b].GetCumulativeCount())
+	if b > 0 {
+		bucketStart = buckets[b-1].GetUpperBound()
+		count -= float64(buckets[b-1].GetCumulativeCount())
+		rank -= float64(buckets[b-1].GetCumulativeCount())
+	}
+	return bucketStart + (bucketEnd-bucketStart)*(rank/count)
+}
+
+// AssertAllHistogramBucketsMonotonic asserts that the cumulative bucket counts of every series of a
+// histogram family in the snapshot never decrease, and never exceed the sample count.
+func (s *Snapshot) AssertAllHistogramBucketsMonotonic(name string) {
+	s.t.Helper()
+	if err := s.typeError(dto.MetricType_HISTOGRAM, name); err != nil {
+		s.report(name, err)
+		return
+	}
+	family, _ := s.family(name)
+	for _, m := range family.GetMetric() {
+		histogram := m.GetHistogram()
+		var previous *dto.Bucket
+		for _, bucket := range sortedBuckets(histogram) {
+			if previous != nil && bucket.GetCumulativeCount() < previous.GetCumulativeCount() {
+				s.errorf(name, "Histogram %s with the labels %v has %d observations <= %f but only %d <= %f",
+					name, labelMap(m), previous.GetCumulativeCount(), previous.GetUpperBound(),
+					bucket.GetCumulativeCount(), bucket.GetUpperBound())
+			}
+			if bucket.GetCumulativeCount() > histogram.GetSampleCount() {
+				s.errorf(name, "Histogram %s with the labels %v has %d observations <= %f but a sample count of %d",
+					name, labelMap(m), bucket.GetCumulativeCount(), bucket.GetUpperBound(), histogram.GetSampleCount())
+			}
+			previous = bucket
+		}
+	}
+}
+
+// AssertHistogramPerBucketCounts asserts the number of observations of a histogram in the
+// snapshot that fell into each of the given bands. Unlike bucket counts, these counts are not
+// cumulative: the band of an upper bound le holds the observations greater than the previous upper
+// bound and less or equal to le, and the band of +Inf holds the observations above the highest upper
+// bound. Bands missing from expected are not checked.
+func (s *Snapshot) AssertHistogramPerBucketCounts(name string, labels map[string]string, expected map[float64]uint64) {
+	s.t.Helper()
+	metric, err := s.findMetric(dto.MetricType_HISTOGRAM, name, labels)
+	if err != nil {
+		if isNotFound(err) && allZero(expected) {
+			// Histogram not existing is the same as the histogram having 0 value
+			return
+		}
+		s.report(name, err)
+		return
+	}
+
+	histogram := metric.GetHistogram()
+	bands := bandCounts(histogram)
+	upperBounds := make([]float64, 0, len(expected))
+	for le := range expected {
+		upperBounds = append(upperBounds, le)
+	}
+	sort.Float64s(upperBounds)
+	for _, le := range upperBounds {
+		actualCount, ok := bands[le]
+		if !ok {
+			s.reportMissingBucket(name, histogram, le)
+			continue
+		}
+		if expectedCount := expected[le]; actualCount != expectedCount {
+			s.errorf(name, "Expected %d observations of histogram [%s] in the band up to %f but was %d",
+				expectedCount, name, le, actualCount)
+		}
+	}
+}
+
+// AssertHistogramBucketExemplarValue asserts that the bucket with the upper bound le of a histogram
+// in the snapshot carries an exemplar with the given value.
+func (s *Snapshot) AssertHistogramBucketExemplarValue(name string, labels map[string]string, le float64, value float64) {
+	s.t.Helper()
+	exemplar, ok := s.bucketExemplar(name, labels, le)
+	if !ok {
+		return
+	}
+
+	if actualValue := exemplar.GetValue(); !s.floatEquals(actualValue, value) {
+		s.errorf(name, "Expected exemplar of histogram [%s] bucket with upper bound %f to have value %f but was %f",
+			name, le, value, actualValue)
+	}
+}
+
+// AssertHistogramBucketExemplar asserts that the bucket with the upper bound le of a histogram in
+// the snapshot carries an exemplar with exactly the given labels, e.g. a trace_id.
+func (s *Snapshot) AssertHistogramBucketExemplar(name string, labels map[string]string, le float64,
+	exemplarLabels map[string]string) {
+	s.t.Helper()
+	exemplar, ok := s.bucketExemplar(name, labels, le)
+	if !ok {
+		return
+	}
+
+	if actualLabels := labelPairsMap(exemplar.GetLabel()); !labelsEqual(actualLabels, exemplarLabels) {
+		s.errorf(name, "Expected exemplar of histogram [%s] bucket with upper bound %f to have the labels %v but had %v",
+			name, le, exemplarLabels, actualLabels)
+	}
+}
+
+// bucketExemplar returns the exemplar of the bucket with the upper bound le of a histogram, failing
+// the test if there is no such histogram, bucket or exemplar.
+func (s *Snapshot) bucketExemplar(name string, labels map[string]string, le float64) (*dto.Exemplar, bool) {
+	s.t.Helper()
+	metric, err := s.findMetric(dto.MetricType_HISTOGRAM, name, labels)
+	if err != nil {
+		s.report(name, err)
+		return nil, false
+	}
+
+	histogram := metric.GetHistogram()
+	bucket := findBucket(histogram, le)
+	if bucket == nil {
+		s.reportMissingBucket(name, histogram, le)
+		return nil, false
+	}
+	exemplar := bucket.GetExemplar()
+	if exemplar == nil {
+		s.errorf(name, "Histogram %s bucket with upper bound %f has no exemplar", name, le)
+		return nil, false
+	}
+	return exemplar, true
+}
+
+// AssertHistogramEqualIgnoringSum asserts that a histogram has the same sample count and bucket
+// counts in this snapshot and the other one, ignoring its sample sum. A histogram missing from both
+// snapshots is considered equal.
+func (s *Snapshot) AssertHistogramEqualIgnoringSum(other *Snapshot, name string, labels map[string]string) {
+	s.t.Helper()
+	metric, err := s.findMetric(dto.MetricType_HISTOGRAM, name, labels)
+	if err != nil && !isNotFound(err) {
+		s.report(name, err)
+		return
+	}
+	otherMetric, otherErr := other.findMetric(dto.MetricType_HISTOGRAM, name, labels)
+	if otherErr != nil && !isNotFound(otherErr) {
+		s.report(name, otherErr)
+		return
+	}
+	switch {
+	case metric == nil && otherMetric == nil:
+		return
+	case metric == nil:
+		s.report(name, err)
+		return
+	case otherMetric == nil:
+		s.errorf(name, "Could not find Histogram %s with the labels %v in the other snapshot", name, labels)
+		return
+	}
+
+	histogram, otherHistogram := metric.GetHistogram(), otherMetric.GetHistogram()
+	buckets, otherBuckets := sortedBuckets(histogram), sortedBuckets(otherHistogram)
+	if len(buckets) != len(otherBuckets) {
+		s.errorf(name, "Histogram %s has the upper bounds %s but %s in the other snapshot",
+			name, formatUpperBounds(histogram), formatUpperBounds(otherHistogram))
+		return
+	}
+	for i, bucket := range buckets {
+		otherBucket := otherBuckets[i]
+		if !floatEquals(bucket.GetUpperBound(), otherBucket.GetUpperBound(), defaultTolerance) {
+			s.errorf(name, "Histogram %s has the upper bounds %s but %s in the other snapshot",
+				name, formatUpperBounds(histogram), formatUpperBounds(otherHistogram))
+			return
+		}
+		if bucket.GetCumulativeCount() != otherBucket.GetCumulativeCount() {
+			s.errorf(name, "Histogram %s has %d observations <= %f but %d in the other snapshot",
+				name, bucket.GetCumulativeCount(), bucket.GetUpperBound(), otherBucket.GetCumulativeCount())
+			return
+		}
+	}
+	if count, otherCount := histogram.GetSampleCount(), otherHistogram.GetSampleCount(); count != otherCount {
+		s.errorf(name, "Histogram %s has a sample count of %d but %d in the other snapshot", name, count, otherCount)
+	}
+}
+
+// bandCounts returns the non-cumulative number of observations of every bucket of a histogram,
+// including the +Inf bucket, by upper bound.
+func bandCounts(histogram *dto.Histogram) map[float64]uint64 {
+	bands := make(map[float64]uint64, len(histogram.GetBucket())+1)
+	var previous uint64
+	for _, bucket := range sortedBuckets(histogram) {
+		bands[bucket.GetUpperBound()] = bucket.GetCumulativeCount() - previous
+		previous = bucket.GetCumulativeCount()
+	}
+	if _, ok := bands[math.Inf(1)]; !ok {
+		bands[math.Inf(1)] = histogram.GetSampleCount() - previous
+	}
+	return bands
+}
+
+// allZero reports whether every count of counts, e.g. expected bucket counts, is 0.
+func allZero(counts map[float64]uint64) bool {
+	for _, count := range counts {
+		if count != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// sortedBuckets returns the buckets of a histogram ordered by their upper bound. Upper bounds may
+// be zero or negative, e.g. for temperature histograms.
+func sortedBuckets(histogram *dto.Histogram) []*dto.Bucket {
+	buckets := append([]*dto.Bucket(nil), histogram.GetBucket()...)
+	sort.SliceStable(buckets, func(i, j int) bool {
+		return buckets[i].GetUpperBound() < buckets[j].GetUpperBound()
+	})
+	return buckets
+}
+
+// formatUpperBounds lists the upper bounds of the buckets of a histogram in ascending order.
+func formatUpperBounds(histogram *dto.Histogram) string {
+	bounds := make([]string, 0, len(histogram.GetBucket()))
+	for _, bucket := range sortedBuckets(histogram) {
+		bounds = append(bounds, formatFloat(bucket.GetUpperBound()))
+	}
+	return "[" + strings.Join(bounds, ", ") + "]"
+}
+
+// reportMissingBucket fails the test because a histogram has no bucket with the upper bound le.
+func (s *Snapshot) reportMissingBucket(name string, histogram *dto.Histogram, le float64) {
+	s.t.Helper()
+	s.report(name, s.missingBucketError(name, histogram, le))
+}
+
+// missingBucketError describes why a histogram has no bucket with the upper bound le, pointing out
+// native histograms, whose buckets are not addressed by upper bound.
+func (s *Snapshot) missingBucketError(name string, histogram *dto.Histogram, le float64) error {
+	if len(histogram.GetBucket()) == 0 && isNativeHistogram(histogram) {
+		return fmt.Errorf("Histogram %s is a native histogram without classic buckets, it has no bucket with upper bound %s",
+			name, s.formatValue(le))
+	}
+	return fmt.Errorf("Histogram %s has no bucket with upper bound %s, its upper bounds are %s",
+		name, s.formatValue(le), formatUpperBounds(histogram))
+}
+
+// findBucket returns the bucket of a histogram with the upper bound le. The implicit +Inf bucket,
+// which gathered histograms leave out, holds every observation.
+func findBucket(histogram *dto.Histogram, le float64) *dto.Bucket {
+	for _, bucket := range histogram.GetBucket() {
+		if floatEquals(bucket.GetUpperBound(), le, defaultTolerance) {
+			return bucket
+		}
+	}
+	if math.IsInf(le, 1) {
+		sampleCount := histogram.GetSampleCount()
+		return &dto.Bucket{UpperBound: &le, CumulativeCount: &sampleCount}
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package promtest
+
+import (
+	"math"
+	"testing"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// newNegativeBoundsSnapshot returns a snapshot of a histogram foo with the buckets -10, 0 and 10,
+// listed out of order, and the observations -20, -5, 0, 5 and 20.
+func newNegativeBoundsSnapshot(t TB) *Snapshot {
+	bucket := func(le float64, cumulativeCount uint64) *dto.Bucket {
+		return &dto.Bucket{UpperBound: &le, CumulativeCount: &cumulativeCount}
+	}
+	name, sampleCount, sampleSum := "foo", uint64(5), float64(0)
+	metricType := dto.MetricType_HISTOGRAM
+	return NewSnapshotFromFamilies(t, []*dto.MetricFamily{{
+		Name: &name,
+		Type: &metricType,
+		Metric: []*dto.Metric{{
+			Histogram: &dto.Histogram{
+				SampleCount: &sampleCount,
+				SampleSum:   &sampleSum,
+				Bucket:      []*dto.Bucket{bucket(10, 4), bucket(-10, 1), bucket(0, 3)},
+			},
+		}},
+	}})
+}
+
+func TestHistogramNegativeAndZeroBounds(t *testing.T) {
+	tests := []struct {
+		name    string
+		assert  func(s *Snapshot)
+		failure string
+	}{
+		{
+			name: "AssertHistogramBucket",
+			assert: func(s *Snapshot) {
+				s.AssertHistogramBucket("foo", nil, -10, 1)
+				s.AssertHistogramBucket("foo", nil, 0, 3)
+				s.AssertHistogramBucket("foo", nil, 10, 4)
+				s.AssertHistogramBucket("foo", nil, math.Inf(1), 5)
+			},
+		},
+		{
+			name: "AssertHistogramBucketCount",
+			assert: func(s *Snapshot) {
+				s.AssertHistogramBucketCount("foo", nil, -10, 1)
+				s.AssertHistogramBucketCount("foo", nil, 0, 3)
+				s.AssertHistogramBucketCount("foo", nil, 10, 4)
+				s.AssertHistogramBucketCount("foo", nil, math.Inf(1), 5)
+			},
+		},
+		{
+			name:    "AssertHistogramBucketCount of the zero bucket",
+			assert:  func(s *Snapshot) { s.AssertHistogramBucketCount("foo", nil, 0, 2) },
+			failure: "Expected 2 observations of histogram [foo] to be <= 0 but was 3",
+		},
+		{
+			name: "AssertHistogramBandCount",
+			assert: func(s *Snapshot) {
+				s.AssertHistogramBandCount("foo", nil, -10, 1)
+				s.AssertHistogramBandCount("foo", nil, 0, 2)
+				s.AssertHistogramBandCount("foo", nil, 10, 1)
+				s.AssertHistogramBandCount("foo", nil, math.Inf(1), 1)
+			},
+		},
+		{
+			name:    "AssertHistogramBandCount of the zero band",
+			assert:  func(s *Snapshot) { s.AssertHistogramBandCount("foo", nil, 0, 3) },
+			failure: "Expected 3 observations of histogram [foo] in the band up to 0 but was 2",
+		},
+		{
+			name:    "AssertHistogramBandCount of the negative band",
+			assert:  func(s *Snapshot) { s.AssertHistogramBandCount("foo", nil, -10, 0) },
+			failure: "Expected 0 observations of histogram [foo] in the band up to -10 but was 1",
+		},
+		{
+			name:    "AssertHistogramBandCount of a missing bucket",
+			assert:  func(s *Snapshot) { s.AssertHistogramBandCount("foo", nil, -5, 1) },
+			failure: "Histogram foo has no bucket with upper bound -5, its upper bounds are [-10, 0, 10]",
+		},
+		{
+			name:   "AssertHistogramBuckets",
+			assert: func(s *Snapshot) { s.AssertHistogramBuckets("foo", nil, map[float64]uint64{10: 4, -10: 1, 0: 3}) },
+		},
+		{
+			name: "AssertHistogramBuckets with +Inf",
+			assert: func(s *Snapshot) {
+				s.AssertHistogramBuckets("foo", nil, map[float64]uint64{10: 4, -10: 1, 0: 3, math.Inf(1): 5})
+			},
+		},
+		{
+			name:    "AssertHistogramBuckets without the zero bucket",
+			assert:  func(s *Snapshot) { s.AssertHistogramBuckets("foo", nil, map[float64]uint64{10: 4, -10: 1}) },
+			failure: "Expected histogram [foo] upper bounds to be [-10, 10] but were [-10, 0, 10]",
+		},
+		{
+			name:    "AssertHistogramBuckets with a wrong bound",
+			assert:  func(s *Snapshot) { s.AssertHistogramBuckets("foo", nil, map[float64]uint64{10: 4, -5: 1, 0: 3}) },
+			failure: "Expected histogram [foo] upper bounds to be [-5, 0, 10] but were [-10, 0, 10]",
+		},
+		{
+			name:    "AssertHistogramBuckets with a wrong cumulative count",
+			assert:  func(s *Snapshot) { s.AssertHistogramBuckets("foo", nil, map[float64]uint64{10: 4, -10: 1, 0: 2}) },
+			failure: "Expected 2 observations of histogram [foo] to be <= 0 but was 3",
+		},
+		{
+			name: "AssertHistogramPerBucketCounts",
+			assert: func(s *Snapshot) {
+				s.AssertHistogramPerBucketCounts("foo", nil, map[float64]uint64{10: 1, -10: 1, 0: 2, math.Inf(1): 1})
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ft := &fakeTB{}
+			tt.assert(newNegativeBoundsSnapshot(ft))
+			if tt.failure == "" {
+				if len(ft.failures) != 0 {
+					t.Errorf("Expected no failure but got %q", ft.failures)
+				}
+				return
+			}
+			assertSingleFailure(t, ft, tt.failure)
+		})
+	}
+}
+
+func TestHistogramBucketsOfMissingHistogram(t *testing.T) {
+	ft := &fakeTB{}
+	s := NewSnapshotFromFamilies(ft, nil)
+	s.AssertHistogramBuckets("foo", nil, map[float64]uint64{-10: 0, 0: 0, 10: 0})
+	s.AssertHistogramPerBucketCounts("foo", nil, map[float64]uint64{0: 0})
+	if len(ft.failures) != 0 {
+		t.Fatalf("Expected a missing histogram to have 0 observations in every bucket but got %q", ft.failures)
+	}
+
+	s.AssertHistogramBuckets("foo", nil, map[float64]uint64{-10: 0, 0: 1})
+	assertSingleFailure(t, ft, "Could not find Histogram foo")
+}
+
+func TestHistogramBucketsOfAnotherType(t *testing.T) {
+	ft := &fakeTB{}
+	r := NewTestRegistry(ft)
+	r.MustRegister(newFooSummary())
+	s, err := r.TakeSnapshot()
+	if err != nil {
+		t.Fatalf("Could not take a snapshot: %v", err)
+	}
+	s.AssertHistogramBuckets("foo", map[string]string{"code": "200"}, map[float64]uint64{1: 0})
+	assertSingleFailure(t, ft, "foo is a SUMMARY, not a HISTOGRAM")
+}
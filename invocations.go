@@ -0,0 +1,124 @@
+package promtest
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Register registers a collector like prometheus.Registry.Register, counting how many times it is
+// collected for AssertCollectorInvocations.
+func (r *TestRegistry) Register(c prometheus.Collector) error {
+	cc := r.invocations.wrap(c)
+	err := r.Registry.Register(cc)
+	var exists prometheus.AlreadyRegisteredError
+	if errors.As(err, &exists) {
+		if existing, ok := exists.ExistingCollector.(*countingCollector); ok {
+			exists.ExistingCollector = existing.Collector
+		}
+		return exists
+	}
+	return err
+}
+
+// MustRegister registers collectors like prometheus.Registry.MustRegister, counting how many times
+// they are collected for AssertCollectorInvocations.
+func (r *TestRegistry) MustRegister(cs ...prometheus.Collector) {
+	for _, c := range cs {
+		if err := r.Register(c); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// Unregister unregisters a collector like prometheus.Registry.Unregister.
+func (r *TestRegistry) Unregister(c prometheus.Collector) bool {
+	return r.Registry.Unregister(r.invocations.wrap(c))
+}
+
+// GatherN takes n snapshots of the registry in a row, e.g. to assert what a GaugeFunc returns on
+// every gather.
+func (r *TestRegistry) GatherN(n int) ([]*Snapshot, error) {
+	snapshots := make([]*Snapshot, 0, n)
+	for i := 0; i < n; i++ {
+		snapshot, err := r.TakeSnapshot()
+		if err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	return snapshots, nil
+}
+
+// AssertCollectorInvocations asserts that a collector registered with the registry was collected
+// exactly n times, i.e. once per gather of the registry.
+func (r *TestRegistry) AssertCollectorInvocations(c prometheus.Collector, n int) {
+	r.t.Helper()
+	cc, ok := r.invocations.lookup(c)
+	if !ok {
+		r.t.Errorf("Collector %T was not registered with the registry", c)
+		return
+	}
+	if actual := cc.invocations(); actual != n {
+		r.t.Errorf("Expected collector %T to be collected %d times but was collected %d times", c, n, actual)
+	}
+}
+
+// collectorInvocations holds the counting collectors wrapping the collectors of a registry.
+type collectorInvocations struct {
+	mu         sync.Mutex
+	collectors map[prometheus.Collector]*countingCollector
+}
+
+func newCollectorInvocations() *collectorInvocations {
+	return &collectorInvocations{collectors: make(map[prometheus.Collector]*countingCollector)}
+}
+
+// wrap returns the counting collector wrapping c, or c itself if c cannot be told apart from other
+// collectors.
+func (i *collectorInvocations) wrap(c prometheus.Collector) prometheus.Collector {
+	if !reflect.TypeOf(c).Comparable() {
+		return c
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	cc, ok := i.collectors[c]
+	if !ok {
+		cc = &countingCollector{Collector: c}
+		i.collectors[c] = cc
+	}
+	return cc
+}
+
+func (i *collectorInvocations) lookup(c prometheus.Collector) (*countingCollector, bool) {
+	if !reflect.TypeOf(c).Comparable() {
+		return nil, false
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	cc, ok := i.collectors[c]
+	return cc, ok
+}
+
+// countingCollector counts how many times the wrapped collector is collected
+type countingCollector struct {
+	prometheus.Collector
+
+	mu    sync.Mutex
+	count int
+}
+
+func (c *countingCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	c.count++
+	c.mu.Unlock()
+	c.Collector.Collect(ch)
+}
+
+func (c *countingCollector) invocations() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.count
+}
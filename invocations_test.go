@@ -0,0 +1,71 @@
+package promtest
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestGatherN(t *testing.T) {
+	ft := &fakeTB{}
+	r := NewTestRegistry(ft)
+	collects := newCollectsGauge("collects")
+	r.MustRegister(collects, newFooCounter())
+	snapshots, err := r.GatherN(3)
+	if err != nil {
+		t.Fatalf("Could not gather: %v", err)
+	}
+	if len(snapshots) != 3 {
+		t.Fatalf("Expected 3 snapshots but got %d", len(snapshots))
+	}
+	for i, s := range snapshots {
+		s.AssertGauge("collects", nil, float64(i+1))
+	}
+	r.AssertCollectorInvocations(collects, 3)
+	if len(ft.failures) != 0 {
+		t.Fatalf("Expected no failure but got %q", ft.failures)
+	}
+
+	r.AssertCollectorInvocations(collects, 1)
+	assertSingleFailure(t, ft, "to be collected 1 times but was collected 3 times")
+}
+
+func TestGatherNError(t *testing.T) {
+	r := NewTestRegistry(&fakeTB{})
+	r.MustRegister(failingCollector{})
+	if snapshots, err := r.GatherN(2); err == nil {
+		t.Errorf("Expected an error gathering a failing collector but got %d snapshots", len(snapshots))
+	}
+}
+
+func TestAssertCollectorInvocationsOfUnregisteredCollector(t *testing.T) {
+	ft := &fakeTB{}
+	r := NewTestRegistry(ft)
+	r.AssertCollectorInvocations(newCollectsGauge("collects"), 0)
+	assertSingleFailure(t, ft, "was not registered with the registry")
+}
+
+func TestRegisterCountingCollectors(t *testing.T) {
+	r := NewTestRegistry(&fakeTB{})
+	c := prometheus.NewCounter(prometheus.CounterOpts{Name: "foo", Help: "foo"})
+	if err := r.Register(c); err != nil {
+		t.Fatalf("Could not register: %v", err)
+	}
+
+	err := r.Register(prometheus.NewCounter(prometheus.CounterOpts{Name: "foo", Help: "foo"}))
+	var exists prometheus.AlreadyRegisteredError
+	if !errors.As(err, &exists) {
+		t.Fatalf("Expected an AlreadyRegisteredError but got %v", err)
+	}
+	if exists.ExistingCollector != c {
+		t.Errorf("Expected the existing collector to be the registered counter but was %T", exists.ExistingCollector)
+	}
+
+	if !r.Unregister(c) {
+		t.Errorf("Expected the counter to be unregistered")
+	}
+	if r.Unregister(c) {
+		t.Errorf("Expected the counter to be unregistered only once")
+	}
+}
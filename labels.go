@@ -0,0 +1,381 @@
+package promtest
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// AssertSeriesLabelSets asserts that the series of a metric family in the snapshot have exactly
+// the expected label sets, in any order and regardless of their values.
+func (s *Snapshot) AssertSeriesLabelSets(name string, expected []map[string]string) {
+	s.t.Helper()
+	var actual []map[string]string
+	family, _ := s.family(name)
+	for _, m := range family.GetMetric() {
+		actual = append(actual, labelMap(m))
+	}
+
+	var missing, extra []map[string]string
+	for _, labels := range expected {
+		if !containsLabels(actual, labels) {
+			missing = append(missing, labels)
+		}
+	}
+	for _, labels := range actual {
+		if !containsLabels(expected, labels) {
+			extra = append(extra, labels)
+		}
+	}
+
+	if len(missing) > 0 {
+		s.errorf(name, "Metric %s is missing series with the labels %v", name, missing)
+	}
+	if len(extra) > 0 {
+		s.errorf(name, "Metric %s has unexpected series with the labels %v", name, extra)
+	}
+}
+
+// AssertLabelKeys asserts that every series of a metric family in the snapshot has exactly the
+// expected label names, in any order and regardless of their values.
+func (s *Snapshot) AssertLabelKeys(name string, expected ...string) {
+	s.t.Helper()
+	family, ok := s.family(name)
+	if !ok {
+		s.errorf(name, "Could not find metric %s", name)
+		return
+	}
+
+	for _, m := range family.GetMetric() {
+		labels := labelMap(m)
+		var missing, extra []string
+		for _, key := range expected {
+			if _, ok := labels[key]; !ok {
+				missing = append(missing, key)
+			}
+		}
+		for key := range labels {
+			if !containsString(expected, key) {
+				extra = append(extra, key)
+			}
+		}
+		sort.Strings(extra)
+
+		if len(missing) > 0 {
+			s.errorf(name, "Series %s%s is missing the labels %v", name, formatLabels(labels), missing)
+		}
+		if len(extra) > 0 {
+			s.errorf(name, "Series %s%s has the unexpected labels %v", name, formatLabels(labels), extra)
+		}
+	}
+}
+
+// AssertCounterForEachSeries asserts the value of every series of a counter family in the
+// snapshot. expected is called with the labels of each series and returns its expected value, or
+// false to skip the series.
+func (s *Snapshot) AssertCounterForEachSeries(name string, expected func(labels map[string]string) (float64, bool)) {
+	s.t.Helper()
+	if err := s.typeError(dto.MetricType_COUNTER, name); err != nil {
+		s.report(name, err)
+		return
+	}
+
+	family, _ := s.family(name)
+	for _, m := range family.GetMetric() {
+		labels := labelMap(m)
+		expectedValue, ok := expected(labels)
+		if !ok {
+			continue
+		}
+		if actualValue := m.GetCounter().GetValue(); !s.floatEquals(actualValue, expectedValue) {
+			s.errorf(name, "Expected counter %s%s to be %f but was %f", name, formatLabels(labels), expectedValue, actualValue)
+		}
+	}
+}
+
+// AssertState asserts that a stateset, exposed as one gauge series per state distinguished by
+// stateLabel, is in activeState: the series with the given labels and activeState is 1 and the
+// series of every other state are 0.
+func (s *Snapshot) AssertState(name string, labels map[string]string, stateLabel, activeState string) {
+	s.t.Helper()
+	family, ok := s.family(name)
+	if !ok {
+		s.errorf(name, "Could not find stateset %s", name)
+		return
+	}
+
+	var active []string
+	found := false
+	for _, m := range family.GetMetric() {
+		seriesLabels := labelMap(m)
+		state, ok := seriesLabels[stateLabel]
+		if !ok {
+			continue
+		}
+		delete(seriesLabels, stateLabel)
+		if !labelsEqual(seriesLabels, labels) {
+			continue
+		}
+
+		value, _ := singleValue(family.GetType(), m)
+		if value != 0 {
+			active = append(active, state)
+		}
+		if state == activeState {
+			found = true
+			if value != 1 {
+				s.errorf(name, "Expected state %s of stateset %s with the labels %v to be 1 but was %f",
+					state, name, labels, value)
+			}
+		} else if value != 0 {
+			s.errorf(name, "Expected state %s of stateset %s with the labels %v to be 0 but was %f",
+				state, name, labels, value)
+		}
+	}
+
+	if !found {
+		s.errorf(name, "Could not find state %s of stateset %s with the labels %v", activeState, name, labels)
+	}
+	if len(active) > 1 {
+		s.errorf(name, "Expected a single active state of stateset %s with the labels %v but found %v",
+			name, labels, active)
+	}
+}
+
+// AssertFamilyHasNoSeries asserts that a metric family is either missing from the snapshot or has
+// no series, e.g. a registered vec that was never used.
+func (s *Snapshot) AssertFamilyHasNoSeries(name string) {
+	s.t.Helper()
+	family, _ := s.family(name)
+	if n := len(family.GetMetric()); n > 0 {
+		s.errorf(name, "Expected metric %s to have no series but it has %d", name, n)
+	}
+}
+
+// AssertAbsent asserts that a metric family in the snapshot, of any type, has no series with
+// exactly the given labels, regardless of its value. Use AssertFamilyHasNoSeries to assert that a
+// family has no series at all.
+func (s *Snapshot) AssertAbsent(name string, labels map[string]string) {
+	s.t.Helper()
+	if s.hasSeries(name, labels) {
+		s.errorf(name, "Expected metric %s to have no series with the labels %v but it has one", name, labels)
+	}
+}
+
+// AssertMetricAbsent asserts that a metric family is missing from the snapshot, e.g. a metric that
+// must not be registered. Unlike AssertFamilyHasNoSeries, a family exposed without series fails.
+func (s *Snapshot) AssertMetricAbsent(name string) {
+	s.t.Helper()
+	if family, ok := s.family(name); ok {
+		s.errorf(name, "Expected metric %s to be absent but it is a %s with %d series",
+			name, family.GetType(), len(family.GetMetric()))
+	}
+}
+
+// AssertSeriesAbsent asserts that no series of a metric family in the snapshot has at least the
+// given labels, e.g. that no request failed with the code 500 whatever its other labels. Use
+// AssertAbsent to assert on the series with exactly the given labels only.
+func (s *Snapshot) AssertSeriesAbsent(name string, labels map[string]string) {
+	s.t.Helper()
+	family, _ := s.family(name)
+	if matches, _ := seriesMatching(family, MatchSubset(labels)); len(matches) > 0 {
+		s.errorf(name, "Expected no series of %s with the labels %v but found %d: %s",
+			name, labels, len(matches), strings.Join(matches, ", "))
+	}
+}
+
+// AssertExists asserts that a metric family in the snapshot, of any type, has a series with
+// exactly the given labels, regardless of its value.
+func (s *Snapshot) AssertExists(name string, labels map[string]string) {
+	s.t.Helper()
+	if !s.hasSeries(name, labels) {
+		s.errorf(name, "Could not find metric %s with the labels %v", name, labels)
+	}
+}
+
+func containsLabels(labelSets []map[string]string, labels map[string]string) bool {
+	for _, labelSet := range labelSets {
+		if labelsEqual(labelSet, labels) {
+			return true
+		}
+	}
+	return false
+}
+
+// AssertCountMatching asserts the count of the single counter series in the snapshot whose labels
+// include the given labels. Other labels of the series are ignored. Labels matching several series
+// fail the assertion.
+func (s *Snapshot) AssertCountMatching(name string, labels map[string]string, value float64) {
+	s.t.Helper()
+	metric, err := s.findMetricMatching(dto.MetricType_COUNTER, name, labels)
+	if err != nil {
+		s.report(name, err)
+		return
+	}
+	if actualValue := metric.GetCounter().GetValue(); !s.floatEquals(actualValue, value) {
+		s.report(name, &ValueMismatchError{dto.MetricType_COUNTER, name, labelMap(metric), "value", value, actualValue})
+	}
+}
+
+// AssertGaugeMatching asserts the value of the single gauge series in the snapshot whose labels
+// include the given labels. Other labels of the series are ignored. Labels matching several series
+// fail the assertion.
+func (s *Snapshot) AssertGaugeMatching(name string, labels map[string]string, value float64) {
+	s.t.Helper()
+	metric, err := s.findMetricMatching(dto.MetricType_GAUGE, name, labels)
+	if err != nil {
+		s.report(name, err)
+		return
+	}
+	if actualValue := metric.GetGauge().GetValue(); !s.floatEquals(actualValue, value) {
+		s.report(name, &ValueMismatchError{dto.MetricType_GAUGE, name, labelMap(metric), "value", value, actualValue})
+	}
+}
+
+// GetMetricMatching returns the single series of the snapshot whose labels include the given
+// labels, or nil if there is none. Labels matching several series fail the test.
+func (s *Snapshot) GetMetricMatching(metricType dto.MetricType, name string, labels map[string]string) *dto.Metric {
+	s.t.Helper()
+	metric, err := s.unrecorded().findMetricMatching(metricType, name, labels)
+	if err != nil && !isNotFound(err) {
+		s.report(name, err)
+	}
+	return metric
+}
+
+// findMetricMatching returns the single series of the named family whose labels include the given
+// labels.
+func (s *Snapshot) findMetricMatching(metricType dto.MetricType, name string,
+	labels map[string]string) (*dto.Metric, error) {
+	family, ok := s.family(name)
+	if !ok {
+		return nil, &MetricNotFoundError{metricType, name, labels, nil, s.matchers}
+	}
+	if err := s.typeError(metricType, name); err != nil {
+		return nil, err
+	}
+	if len(s.matchers) > 0 {
+		return s.findMetricMatchers(family, metricType, name, labels)
+	}
+
+	var match *dto.Metric
+	var matches []map[string]string
+	for _, m := range family.GetMetric() {
+		if !hasLabels(labelMap(m), labels) {
+			continue
+		}
+		match = m
+		matches = append(matches, labelMap(m))
+	}
+	switch len(matches) {
+	case 0:
+		return nil, &MetricNotFoundError{metricType, name, labels, closestSeries(family, labels), nil}
+	case 1:
+		return match, nil
+	}
+	return nil, &AmbiguousMatchError{metricType, name, labels, matches}
+}
+
+// hasLabels reports whether labelSet includes every label of labels.
+func hasLabels(labelSet, labels map[string]string) bool {
+	for name, value := range labels {
+		if actual, ok := labelSet[name]; !ok || actual != value {
+			return false
+		}
+	}
+	return true
+}
+
+// AssertSeriesCount asserts the number of series of a metric family in the snapshot. A missing
+// family has no series.
+func (s *Snapshot) AssertSeriesCount(name string, want int) {
+	s.t.Helper()
+	family, _ := s.family(name)
+	if n := len(family.GetMetric()); n != want {
+		s.errorf(name, "Expected metric %s to have %d series but it has %d", name, want, n)
+	}
+}
+
+// AssertMaxCardinality asserts that a metric family in the snapshot has at most limit series, e.g.
+// to catch a label set to a user ID. The labels with the most values are reported on failure.
+func (s *Snapshot) AssertMaxCardinality(name string, limit int) {
+	s.t.Helper()
+	family, _ := s.family(name)
+	n := len(family.GetMetric())
+	if n <= limit {
+		return
+	}
+
+	index := labelValueIndex(family)
+	labelNames := make([]string, 0, len(index))
+	for labelName := range index {
+		labelNames = append(labelNames, labelName)
+	}
+	sort.Slice(labelNames, func(i, j int) bool {
+		if len(index[labelNames[i]]) != len(index[labelNames[j]]) {
+			return len(index[labelNames[i]]) > len(index[labelNames[j]])
+		}
+		return labelNames[i] < labelNames[j]
+	})
+	counts := make([]string, 0, len(labelNames))
+	for _, labelName := range labelNames {
+		counts = append(counts, fmt.Sprintf("%s: %d", labelName, len(index[labelName])))
+	}
+	s.errorf(name, "Expected metric %s to have at most %d series but it has %d, values per label: %s",
+		name, limit, n, strings.Join(counts, ", "))
+}
+
+// AssertLabelValues asserts the distinct values a label takes across the series of a metric family
+// in the snapshot, in any order. Series without the label are ignored.
+func (s *Snapshot) AssertLabelValues(name string, label string, want []string) {
+	s.t.Helper()
+	family, ok := s.family(name)
+	if !ok {
+		s.errorf(name, "Could not find metric %s", name)
+		return
+	}
+
+	actual := labelValueIndex(family)[label]
+	expected := make(map[string]bool, len(want))
+	for _, value := range want {
+		expected[value] = true
+	}
+	var missing, extra []string
+	for value := range expected {
+		if !actual[value] {
+			missing = append(missing, value)
+		}
+	}
+	for value := range actual {
+		if !expected[value] {
+			extra = append(extra, value)
+		}
+	}
+	sort.Strings(missing)
+	sort.Strings(extra)
+
+	if len(missing) > 0 {
+		s.errorf(name, "Label %s of metric %s is missing the values %q", label, name, missing)
+	}
+	if len(extra) > 0 {
+		s.errorf(name, "Label %s of metric %s has the unexpected values %q", label, name, extra)
+	}
+}
+
+// labelValueIndex returns the distinct values of every label across the series of a family.
+func labelValueIndex(family *dto.MetricFamily) map[string]map[string]bool {
+	index := make(map[string]map[string]bool)
+	for _, m := range family.GetMetric() {
+		for _, labelPair := range m.GetLabel() {
+			values, ok := index[labelPair.GetName()]
+			if !ok {
+				values = make(map[string]bool)
+				index[labelPair.GetName()] = values
+			}
+			values[labelPair.GetValue()] = true
+		}
+	}
+	return index
+}
@@ -0,0 +1,133 @@
+package promtest
+
+import (
+	"testing"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestAssertMetricAbsent(t *testing.T) {
+	name, metricType := "idle_total", dto.MetricType_COUNTER
+	tests := []struct {
+		name    string
+		metric  string
+		failure string
+	}{
+		{"missing family", "missing_total", ""},
+		{"family with series", "http_requests_total", "Expected metric http_requests_total to be absent but it is a COUNTER with 3 series"},
+		{"family without series", "idle_total", "Expected metric idle_total to be absent but it is a COUNTER with 0 series"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ft := &fakeTB{}
+			s := newSnapshotOf(t, ft, newRequestsCounter())
+			s.MetricMap[name] = &dto.MetricFamily{Name: &name, Type: &metricType}
+			s.AssertMetricAbsent(tt.metric)
+			if tt.failure == "" {
+				if len(ft.failures) != 0 {
+					t.Errorf("Expected no failure but got %q", ft.failures)
+				}
+				return
+			}
+			assertSingleFailure(t, ft, tt.failure)
+		})
+	}
+}
+
+func TestAssertSeriesAbsent(t *testing.T) {
+	tests := []struct {
+		name    string
+		labels  map[string]string
+		failure string
+	}{
+		{"no series with the labels", map[string]string{"code": "404"}, ""},
+		{"no series with every label", map[string]string{"code": "500", "instance": "host-2"}, ""},
+		{
+			name:    "series with at least the labels",
+			labels:  map[string]string{"code": "500"},
+			failure: `Expected no series of http_requests_total with the labels map[code:500] but found 1: http_requests_total{code="500",instance="host-1"}`,
+		},
+		{
+			name:    "every series",
+			labels:  nil,
+			failure: "Expected no series of http_requests_total with the labels map[] but found 3",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ft := &fakeTB{}
+			newSnapshotOf(t, ft, newRequestsCounter()).AssertSeriesAbsent("http_requests_total", tt.labels)
+			if tt.failure == "" {
+				if len(ft.failures) != 0 {
+					t.Errorf("Expected no failure but got %q", ft.failures)
+				}
+				return
+			}
+			assertSingleFailure(t, ft, tt.failure)
+		})
+	}
+}
+
+func TestCardinalityAssertions(t *testing.T) {
+	tests := []struct {
+		name     string
+		assert   func(s *Snapshot)
+		failures []string
+	}{
+		{
+			name: "within limits",
+			assert: func(s *Snapshot) {
+				s.AssertSeriesCount("http_requests_total", 3)
+				s.AssertSeriesCount("missing_total", 0)
+				s.AssertMaxCardinality("http_requests_total", 3)
+				s.AssertMaxCardinality("missing_total", 0)
+				s.AssertLabelValues("http_requests_total", "code", []string{"503", "200", "500"})
+				s.AssertLabelValues("http_requests_total", "instance", []string{"host-1", "host-2"})
+			},
+		},
+		{
+			name:     "AssertSeriesCount",
+			assert:   func(s *Snapshot) { s.AssertSeriesCount("http_requests_total", 2) },
+			failures: []string{"Expected metric http_requests_total to have 2 series but it has 3"},
+		},
+		{
+			name:   "AssertMaxCardinality",
+			assert: func(s *Snapshot) { s.AssertMaxCardinality("http_requests_total", 2) },
+			failures: []string{
+				"Expected metric http_requests_total to have at most 2 series but it has 3, values per label: code: 3, instance: 2",
+			},
+		},
+		{
+			name:   "AssertLabelValues",
+			assert: func(s *Snapshot) { s.AssertLabelValues("http_requests_total", "code", []string{"200", "404"}) },
+			failures: []string{
+				`Label code of metric http_requests_total is missing the values ["404"]`,
+				`Label code of metric http_requests_total has the unexpected values ["500" "503"]`,
+			},
+		},
+		{
+			name:     "AssertLabelValues of a missing label",
+			assert:   func(s *Snapshot) { s.AssertLabelValues("http_requests_total", "method", nil) },
+			failures: nil,
+		},
+		{
+			name:     "AssertLabelValues of a missing metric",
+			assert:   func(s *Snapshot) { s.AssertLabelValues("missing_total", "code", nil) },
+			failures: []string{"Could not find metric missing_total"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ft := &fakeTB{}
+			tt.assert(newSnapshotOf(t, ft, newRequestsCounter()))
+			if len(ft.failures) != len(tt.failures) {
+				t.Fatalf("Expected %d failures but got %d: %q", len(tt.failures), len(ft.failures), ft.failures)
+			}
+			for i, failure := range tt.failures {
+				if ft.failures[i] != failure {
+					t.Errorf("Expected failure %d to be %q but was %q", i, failure, ft.failures[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package promtest
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus/testutil/promlint"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// LintRule is a custom lint check of a metric family, returning one error per problem found
+type LintRule func(family *dto.MetricFamily) []error
+
+// WithLint makes every snapshot of the registry lint the gathered metrics with promlint and the
+// given custom rules, failing the test on problems.
+func WithLint(rules ...LintRule) Option {
+	return func(r *TestRegistry) {
+		r.lint = true
+		r.lintRules = append(r.lintRules, rules...)
+	}
+}
+
+// Lint returns the problems found by promlint and the custom rules of WithLint in the metrics
+// gathered from the registry.
+func (r *TestRegistry) Lint() ([]promlint.Problem, error) {
+	families, err := r.Registry.Gather()
+	if err != nil {
+		return nil, err
+	}
+	return r.lintFamilies(families)
+}
+
+// AssertLintClean asserts that the metrics gathered from the registry follow the Prometheus
+// naming and documentation conventions checked by promlint, and the custom rules of WithLint. If
+// metric names are given, only those metrics are linted.
+func (r *TestRegistry) AssertLintClean(metricNames ...string) {
+	r.t.Helper()
+	families, err := r.Registry.Gather()
+	if err != nil {
+		r.t.Errorf("Could not lint metrics: %v", err)
+		return
+	}
+	if len(metricNames) > 0 {
+		var named []*dto.MetricFamily
+		for _, family := range families {
+			if containsString(metricNames, family.GetName()) {
+				named = append(named, family)
+			}
+		}
+		families = named
+	}
+	r.assertLintClean(families)
+}
+
+// assertLintClean fails the test if linting families finds problems.
+func (r *TestRegistry) assertLintClean(families []*dto.MetricFamily) {
+	r.t.Helper()
+	problems, err := r.lintFamilies(families)
+	if err != nil {
+		r.t.Errorf("Could not lint metrics: %v", err)
+		return
+	}
+	if len(problems) == 0 {
+		return
+	}
+
+	lines := make([]string, 0, len(problems))
+	for _, problem := range problems {
+		lines = append(lines, fmt.Sprintf("%s: %s", problem.Metric, problem.Text))
+	}
+	r.t.Errorf("Found %d lint problems:\n%s", len(problems), strings.Join(lines, "\n"))
+}
+
+func (r *TestRegistry) lintFamilies(families []*dto.MetricFamily) ([]promlint.Problem, error) {
+	linter := promlint.NewWithMetricFamilies(families)
+	for _, rule := range r.lintRules {
+		linter.AddCustomValidations(rule)
+	}
+	return linter.Lint()
+}
@@ -0,0 +1,81 @@
+package promtest
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// newUnlintedCollectors returns a gauge with the _total suffix of counters and a counter with a
+// camelCase label.
+func newUnlintedCollectors() []prometheus.Collector {
+	g := prometheus.NewGauge(prometheus.GaugeOpts{Name: "queue_total", Help: "Queued jobs."})
+	c := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "jobs_total", Help: "Jobs run."}, []string{"jobType"})
+	c.WithLabelValues("batch").Inc()
+	return []prometheus.Collector{g, c}
+}
+
+// requireUnit is a custom rule requiring gauges to end with a unit.
+func requireUnit(family *dto.MetricFamily) []error {
+	if family.GetType() == dto.MetricType_GAUGE && !strings.HasSuffix(family.GetName(), "_seconds") {
+		return []error{errors.New("gauges must be in seconds")}
+	}
+	return nil
+}
+
+func TestLint(t *testing.T) {
+	r := NewTestRegistry(&fakeTB{}, WithLint(requireUnit))
+	r.MustRegister(newUnlintedCollectors()...)
+	problems, err := r.Lint()
+	if err != nil {
+		t.Fatalf("Could not lint: %v", err)
+	}
+	var texts []string
+	for _, problem := range problems {
+		texts = append(texts, problem.Metric+": "+problem.Text)
+	}
+	for _, expected := range []string{
+		`queue_total: non-counter metrics should not have "_total" suffix`,
+		"queue_total: gauges must be in seconds",
+		"jobs_total: label names should be written in 'snake_case' not 'camelCase'",
+	} {
+		if !containsString(texts, expected) {
+			t.Errorf("Expected the problem %q but got %q", expected, texts)
+		}
+	}
+}
+
+func TestWithLintFailsSnapshots(t *testing.T) {
+	ft := &fakeTB{}
+	r := NewTestRegistry(ft, WithLint())
+	r.MustRegister(newUnlintedCollectors()...)
+	if _, err := r.TakeSnapshot(); err != nil {
+		t.Fatalf("Could not take a snapshot: %v", err)
+	}
+	assertSingleFailure(t, ft, `Found 2 lint problems:
+jobs_total: label names should be written in 'snake_case' not 'camelCase'
+queue_total: non-counter metrics should not have "_total" suffix`)
+}
+
+func TestAssertLintClean(t *testing.T) {
+	ft := &fakeTB{}
+	r := NewTestRegistry(ft)
+	clean := prometheus.NewCounter(prometheus.CounterOpts{Name: "processed_total", Help: "Processed jobs."})
+	r.MustRegister(clean)
+	r.AssertLintClean()
+	if len(ft.failures) != 0 {
+		t.Fatalf("Expected no lint problem but got %q", ft.failures)
+	}
+
+	r.MustRegister(newUnlintedCollectors()...)
+	r.AssertLintClean("processed_total")
+	if len(ft.failures) != 0 {
+		t.Fatalf("Expected only processed_total to be linted but got %q", ft.failures)
+	}
+	r.AssertLintClean("queue_total")
+	assertSingleFailure(t, ft, `Found 1 lint problems:
+queue_total: non-counter metrics should not have "_total" suffix`)
+}
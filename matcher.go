@@ -0,0 +1,226 @@
+package promtest
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// LabelMatcher matches a label of a series, like a label matcher of a PromQL selector
+type LabelMatcher struct {
+	name    string
+	value   string
+	regex   bool
+	present bool
+}
+
+// Label matches series whose label called name has exactly the given value.
+func Label(name, value string) LabelMatcher {
+	return LabelMatcher{name: name, value: value}
+}
+
+// LabelPresent matches series that have a label called name, whatever its value.
+func LabelPresent(name string) LabelMatcher {
+	return LabelMatcher{name: name, present: true}
+}
+
+// MatchSubset matches series whose labels include the given labels, ignoring their other labels.
+func MatchSubset(labels map[string]string) []LabelMatcher {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	matchers := make([]LabelMatcher, 0, len(names))
+	for _, name := range names {
+		matchers = append(matchers, Label(name, labels[name]))
+	}
+	return matchers
+}
+
+// Regex returns a copy of the matcher that treats its value as a regular expression which must
+// match the whole label value, e.g. Label("code", "5..").Regex().
+func (m LabelMatcher) Regex() LabelMatcher {
+	m.regex = true
+	return m
+}
+
+func (m LabelMatcher) String() string {
+	switch {
+	case m.present:
+		return m.name + `!=""`
+	case m.regex:
+		return fmt.Sprintf("%s=~%q", m.name, m.value)
+	}
+	return fmt.Sprintf("%s=%q", m.name, m.value)
+}
+
+// matches reports whether the labels of a series satisfy the matcher.
+func (m LabelMatcher) matches(labels map[string]string) (bool, error) {
+	value, ok := labels[m.name]
+	switch {
+	case m.present:
+		return ok, nil
+	case m.regex:
+		re, err := regexp.Compile("^(?:" + m.value + ")$")
+		if err != nil {
+			return false, fmt.Errorf("Invalid regular expression in label matcher %s: %v", m, err)
+		}
+		return re.MatchString(value), nil
+	}
+	return ok && value == m.value, nil
+}
+
+// Matching returns a view of the snapshot whose assertions and getters on a single series select it
+// by the matchers and the labels they are given, ignoring the other labels of the series, e.g.
+//
+//	s.Matching(LabelPresent("instance")).AssertCount("http_requests_total", map[string]string{"code": "500"}, 1)
+//
+// Like with exact labels, no matching series is the same as the series having 0 value, and several
+// matching series fail the assertion. Assertions on every series of a family are not affected.
+func (s *Snapshot) Matching(matchers ...LabelMatcher) *Snapshot {
+	c := *s
+	c.matchers = append(append([]LabelMatcher(nil), s.matchers...), matchers...)
+	return &c
+}
+
+// GetMetricMatchers returns the single series of the snapshot matched by every matcher, or nil if
+// there is none. Matchers matching several series fail the test.
+func (s *Snapshot) GetMetricMatchers(metricType dto.MetricType, name string, matchers ...LabelMatcher) *dto.Metric {
+	s.t.Helper()
+	// Getters do not count as assertions on the family
+	s = s.unrecorded()
+	matchers = append(append([]LabelMatcher(nil), s.matchers...), matchers...)
+	family, ok := s.family(name)
+	if !ok {
+		return nil
+	}
+	if err := s.typeError(metricType, name); err != nil {
+		s.report(name, err)
+		return nil
+	}
+	metric, err := s.matchSeries(family, name, matchers)
+	if err != nil {
+		s.report(name, err)
+	}
+	return metric
+}
+
+// SelectLabels returns the labels of the single series of a metric family in the snapshot matched
+// by every matcher, to be passed to any assertion, e.g.
+//
+//	s.AssertCount("http_requests_total", s.SelectLabels("http_requests_total", Label("code", "5..").Regex()), 1)
+//
+// No match or several matches fail the test, and nil is returned.
+func (s *Snapshot) SelectLabels(name string, matchers ...LabelMatcher) map[string]string {
+	s.t.Helper()
+	// Getters do not count as assertions on the family
+	s = s.unrecorded()
+	matchers = append(append([]LabelMatcher(nil), s.matchers...), matchers...)
+	family, ok := s.family(name)
+	if !ok {
+		s.errorf(name, "Could not find metric %s", name)
+		return nil
+	}
+	metric, err := s.matchSeries(family, name, matchers)
+	if err == nil && metric == nil {
+		err = fmt.Errorf("Could not find %s %s matching %s", typeTitle(family.GetType()), name, formatMatchers(matchers))
+	}
+	if err != nil {
+		s.report(name, err)
+		return nil
+	}
+	return labelMap(metric)
+}
+
+// AssertNoSamplesMatching asserts that no series of a metric family in the snapshot is matched by
+// every matcher, e.g. that no request failed with Label("code", "5..").Regex().
+func (s *Snapshot) AssertNoSamplesMatching(name string, matchers ...LabelMatcher) {
+	s.t.Helper()
+	family, _ := s.family(name)
+	matches, err := seriesMatching(family, matchers)
+	if err != nil {
+		s.report(name, err)
+		return
+	}
+	if len(matches) > 0 {
+		s.errorf(name, "Expected no series of %s matching %s but found %d: %s",
+			name, formatMatchers(matchers), len(matches), strings.Join(matches, ", "))
+	}
+}
+
+// seriesMatching returns the sorted selectors of the series of a family matched by every matcher.
+func seriesMatching(family *dto.MetricFamily, matchers []LabelMatcher) ([]string, error) {
+	var matches []string
+	for _, m := range family.GetMetric() {
+		labels := labelMap(m)
+		ok, err := matchesAll(matchers, labels)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			matches = append(matches, family.GetName()+formatLabels(labels))
+		}
+	}
+	sort.Strings(matches)
+	return matches, nil
+}
+
+// findMetricMatchers returns the single series of a family matched by both the labels and the
+// matchers of the snapshot.
+func (s *Snapshot) findMetricMatchers(family *dto.MetricFamily, metricType dto.MetricType, name string,
+	labels map[string]string) (*dto.Metric, error) {
+	metric, err := s.matchSeries(family, name, append(MatchSubset(labels), s.matchers...))
+	if err != nil {
+		return nil, err
+	}
+	if metric == nil {
+		return nil, &MetricNotFoundError{metricType, name, labels, closestSeries(family, labels), s.matchers}
+	}
+	return metric, nil
+}
+
+// matchSeries returns the single series of a family matched by every matcher, or nil if there is
+// none.
+func (s *Snapshot) matchSeries(family *dto.MetricFamily, name string, matchers []LabelMatcher) (*dto.Metric, error) {
+	var match *dto.Metric
+	var matches []string
+	for _, m := range family.GetMetric() {
+		labels := labelMap(m)
+		ok, err := matchesAll(matchers, labels)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			match = m
+			matches = append(matches, formatLabels(labels))
+		}
+	}
+	if len(matches) > 1 {
+		return nil, fmt.Errorf("The matchers %s match %d series of %s %s: %s", formatMatchers(matchers),
+			len(matches), typeTitle(family.GetType()), name, strings.Join(matches, ", "))
+	}
+	return match, nil
+}
+
+func matchesAll(matchers []LabelMatcher, labels map[string]string) (bool, error) {
+	for _, matcher := range matchers {
+		if ok, err := matcher.matches(labels); !ok || err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// formatMatchers renders matchers like a PromQL selector, e.g. {code=~"5..",instance!=""}
+func formatMatchers(matchers []LabelMatcher) string {
+	parts := make([]string, 0, len(matchers))
+	for _, matcher := range matchers {
+		parts = append(parts, matcher.String())
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
@@ -0,0 +1,91 @@
+package promtest
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestMatching(t *testing.T) {
+	ft := &fakeTB{}
+	r := NewTestRegistry(ft)
+	c := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "http_requests_total", Help: "h"},
+		[]string{"code", "instance"})
+	r.MustRegister(c)
+	c.WithLabelValues("200", "host-1").Add(3)
+	c.WithLabelValues("500", "host-1").Add(1)
+	c.WithLabelValues("503", "host-2").Add(2)
+	s, err := r.TakeSnapshot()
+	if err != nil {
+		t.Fatalf("Could not take a snapshot: %v", err)
+	}
+
+	present := s.Matching(LabelPresent("instance"))
+	present.AssertCount("http_requests_total", map[string]string{"code": "200"}, 3)
+	present.AssertCount("http_requests_total", map[string]string{"code": "404"}, 0)
+	s.Matching(Label("code", "5..").Regex(), Label("instance", "host-2")).AssertCount("http_requests_total", nil, 2)
+	if m := present.GetMetric(dto.MetricType_COUNTER, "http_requests_total", map[string]string{"code": "500"}); m == nil ||
+		m.GetCounter().GetValue() != 1 {
+		t.Errorf("Expected GetMetric to return the series with the code 500 but was %v", m)
+	}
+	if labels := present.SelectLabels("http_requests_total", Label("code", "503")); labels["instance"] != "host-2" {
+		t.Errorf("Expected SelectLabels to return the series with the code 503 but was %v", labels)
+	}
+	if len(ft.failures) != 0 {
+		t.Fatalf("Expected no failure but got %q", ft.failures)
+	}
+
+	s.Matching(Label("code", "5..").Regex()).AssertCount("http_requests_total", nil, 3)
+	assertSingleFailure(t, ft, "match 2 series of Counter http_requests_total")
+
+	ft.failures = nil
+	present.AssertCount("http_requests_total", map[string]string{"code": "404"}, 1)
+	assertSingleFailure(t, ft, `Could not find Counter http_requests_total with the labels map[code:404] matching {instance!=""}`)
+}
+
+func newRequestsCounter() prometheus.Collector {
+	c := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "http_requests_total", Help: "h"},
+		[]string{"code", "instance"})
+	c.WithLabelValues("200", "host-1").Add(3)
+	c.WithLabelValues("500", "host-1").Add(1)
+	c.WithLabelValues("503", "host-2").Add(2)
+	return c
+}
+
+func TestAssertNoSamplesMatching(t *testing.T) {
+	tests := []struct {
+		name     string
+		matchers []LabelMatcher
+		failure  string
+	}{
+		{"no series matching", []LabelMatcher{Label("code", "4..").Regex()}, ""},
+		{"no series matching every matcher", []LabelMatcher{Label("code", "200"), Label("instance", "host-2")}, ""},
+		{
+			name:     "series matching",
+			matchers: []LabelMatcher{Label("code", "5..").Regex()},
+			failure: `Expected no series of http_requests_total matching {code=~"5.."} but found 2: ` +
+				`http_requests_total{code="500",instance="host-1"}, http_requests_total{code="503",instance="host-2"}`,
+		},
+		{"invalid regular expression", []LabelMatcher{Label("code", "5(").Regex()}, "5("},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ft := &fakeTB{}
+			newSnapshotOf(t, ft, newRequestsCounter()).AssertNoSamplesMatching("http_requests_total", tt.matchers...)
+			if tt.failure == "" {
+				if len(ft.failures) != 0 {
+					t.Errorf("Expected no failure but got %q", ft.failures)
+				}
+				return
+			}
+			assertSingleFailure(t, ft, tt.failure)
+		})
+	}
+
+	ft := &fakeTB{}
+	newSnapshotOf(t, ft, newRequestsCounter()).AssertNoSamplesMatching("missing_total", Label("code", "5..").Regex())
+	if len(ft.failures) != 0 {
+		t.Errorf("Expected a missing metric family to have no matching series but got %q", ft.failures)
+	}
+}
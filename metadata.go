@@ -0,0 +1,52 @@
+package promtest
+
+import (
+	dto "github.com/prometheus/client_model/go"
+)
+
+// AssertConsistentType asserts that a metric family exists in the snapshot and is of the expected
+// type.
+func (s *Snapshot) AssertConsistentType(name string, expected dto.MetricType) {
+	s.t.Helper()
+	if _, ok := s.family(name); !ok {
+		s.errorf(name, "Could not find %s %s", typeTitle(expected), name)
+		return
+	}
+	s.report(name, s.typeError(expected, name))
+}
+
+// AssertType asserts that a metric family exists in the snapshot and is of the expected type, like
+// AssertConsistentType.
+func (s *Snapshot) AssertType(name string, metricType dto.MetricType) {
+	s.t.Helper()
+	s.AssertConsistentType(name, metricType)
+}
+
+// AssertHelp asserts that a metric family exists in the snapshot and has the expected help text.
+func (s *Snapshot) AssertHelp(name string, expectedHelp string) {
+	s.t.Helper()
+	family, ok := s.family(name)
+	if !ok {
+		s.errorf(name, "Could not find metric %s", name)
+		return
+	}
+	if actualHelp := family.GetHelp(); actualHelp != expectedHelp {
+		s.errorf(name, "Expected help of metric %s to be %q but was %q", name, expectedHelp, actualHelp)
+	}
+}
+
+// AssertNoTypeDrift asserts that every metric family found in both the baseline snapshot and this
+// one has the same type in each.
+func (s *Snapshot) AssertNoTypeDrift(baseline *Snapshot) {
+	s.t.Helper()
+	for _, name := range sortedNames(baseline.MetricMap) {
+		family, ok := s.MetricMap[name]
+		if !ok {
+			continue
+		}
+		if expected, actual := baseline.MetricMap[name].GetType(), family.GetType(); expected != actual {
+			s.errorf(name, "%s changed from a %s in the baseline to a %s",
+				name, dto.MetricType_name[int32(expected)], dto.MetricType_name[int32(actual)])
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package promtest
+
+import (
+	dto "github.com/prometheus/client_model/go"
+)
+
+// MultiSnapshot provides the assertions of Snapshot over the metrics of several snapshots, such as
+// those of independent registries
+type MultiSnapshot struct {
+	*Snapshot
+}
+
+// NewMultiSnapshot combines several snapshots into a single MultiSnapshot with the settings of the
+// first one, e.g. its tolerance. A metric family found in more than one snapshot must have the same
+// type and help in each, and no series may be present in more than one of them. Assertions are
+// recorded by every snapshot, for Strict registries.
+func NewMultiSnapshot(t TB, snaps ...*Snapshot) *MultiSnapshot {
+	t.Helper()
+	s := NewSnapshotFromFamilies(t, nil)
+	if len(snaps) > 0 {
+		s = snaps[0].combined(t)
+	}
+	trackers := make([]*assertedFamilies, 0, len(snaps))
+	for _, snap := range snaps {
+		trackers = append(trackers, snap.asserted)
+	}
+	s.asserted = newAssertedFamilies(trackers...)
+
+	var families []*dto.MetricFamily
+	seen := make(map[string][]*dto.MetricFamily)
+	for i, snap := range snaps {
+		for _, name := range sortedNames(snap.MetricMap) {
+			family := snap.MetricMap[name]
+			if others := seen[name]; len(others) > 0 {
+				first := others[0]
+				if family.GetType() != first.GetType() || family.GetHelp() != first.GetHelp() {
+					s.errorf(name, "Snapshot %d defines %s as a %s with the help %q, conflicting with a %s with the help %q",
+						i, name, family.GetType(), family.GetHelp(), first.GetType(), first.GetHelp())
+					continue
+				}
+				for _, m := range family.GetMetric() {
+					if labels := labelMap(m); containsSeries(others, labels) {
+						s.errorf(name, "Snapshot %d duplicates the series of %s with the labels %v", i, name, labels)
+					}
+				}
+			}
+			seen[name] = append(seen[name], family)
+			families = append(families, family)
+		}
+	}
+	s.MetricMap = NewSnapshotFromFamilies(t, families).MetricMap
+	return &MultiSnapshot{s}
+}
+
+// combined returns an empty snapshot reporting to t with the settings of the snapshot, e.g. its
+// tolerance and float format, to hold the metric families of several snapshots.
+func (s *Snapshot) combined(t TB) *Snapshot {
+	c := *s
+	c.t = t
+	c.MetricMap = make(map[string]*dto.MetricFamily)
+	return &c
+}
+
+func containsSeries(families []*dto.MetricFamily, labels map[string]string) bool {
+	for _, family := range families {
+		for _, m := range family.GetMetric() {
+			if labelsEqual(labelMap(m), labels) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// MergeOption configures how MergeSnapshots combines two snapshots
+type MergeOption func(o *mergeOptions)
+
+type mergeOptions struct {
+	prefixA, prefixB string
+	failOnDuplicate  bool
+}
+
+// PrefixNames prepends prefixA to the names of the metric families of the first snapshot and
+// prefixB to those of the second, e.g. to tell apart the metrics of two components.
+func PrefixNames(prefixA, prefixB string) MergeOption {
+	return func(o *mergeOptions) {
+		o.prefixA, o.prefixB = prefixA, prefixB
+	}
+}
+
+// FailOnDuplicate makes MergeSnapshots fail the test when both snapshots have a series with the
+// same name and labels, instead of keeping the series of the first snapshot.
+func FailOnDuplicate() MergeOption {
+	return func(o *mergeOptions) {
+		o.failOnDuplicate = true
+	}
+}
+
+// MergeSnapshots combines the metric families of two snapshots into a new snapshot with the
+// settings of a, reporting to its test and recording assertions like a. A metric family found in
+// both snapshots must have the same type and help in each.
+func MergeSnapshots(a, b *Snapshot, opts ...MergeOption) *Snapshot {
+	a.t.Helper()
+	var o mergeOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	s := a.combined(a.t)
+	merged := s.MetricMap
+	for _, name := range sortedNames(a.MetricMap) {
+		merged[o.prefixA+name] = renamedFamily(a.MetricMap[name], o.prefixA+name)
+	}
+	for _, name := range sortedNames(b.MetricMap) {
+		family := b.MetricMap[name]
+		existing, ok := merged[o.prefixB+name]
+		if !ok {
+			merged[o.prefixB+name] = renamedFamily(family, o.prefixB+name)
+			continue
+		}
+		if family.GetType() != existing.GetType() {
+			s.errorf(o.prefixB+name, "Cannot merge %s, it is a %s in the first snapshot and a %s in the second",
+				o.prefixB+name, existing.GetType(), family.GetType())
+			continue
+		}
+		if family.GetHelp() != existing.GetHelp() {
+			s.errorf(o.prefixB+name, "Cannot merge %s, its help is %q in the first snapshot and %q in the second",
+				o.prefixB+name, existing.GetHelp(), family.GetHelp())
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			if labels := labelMap(m); containsSeries([]*dto.MetricFamily{existing}, labels) {
+				if o.failOnDuplicate {
+					s.errorf(o.prefixB+name, "Both snapshots have the series of %s with the labels %v", o.prefixB+name, labels)
+				}
+				continue
+			}
+			existing.Metric = append(existing.Metric, m)
+		}
+	}
+	return s
+}
+
+// renamedFamily returns a copy of a family called name, whose series can be added to without
+// changing the original family.
+func renamedFamily(family *dto.MetricFamily, name string) *dto.MetricFamily {
+	return &dto.MetricFamily{
+		Name:   &name,
+		Help:   family.Help,
+		Type:   family.Type,
+		Unit:   family.Unit,
+		Metric: append([]*dto.Metric(nil), family.GetMetric()...),
+	}
+}
+
+// AssertEquals asserts that the snapshot and the other one hold exactly the same metric families
+// and series with the same values, except for the ignored metric families, e.g. to compare a
+// refactored service with the legacy one. Differences are reported as a line diff.
+func (s *Snapshot) AssertEquals(other *Snapshot, ignore ...string) {
+	s.t.Helper()
+	expected, actual := withoutFamilies(other.MetricMap, ignore), withoutFamilies(s.MetricMap, ignore)
+	s.markAsserted(sortedNames(actual)...)
+	other.markAsserted(sortedNames(expected)...)
+	if diff := diffLines(renderFamilies(expected, s.floatFormat), renderFamilies(actual, s.floatFormat)); diff != "" {
+		s.errorf("", "Metrics differ from the other snapshot (-other +actual):\n%s", diff)
+	}
+}
+
+func withoutFamilies(metricMap map[string]*dto.MetricFamily, names []string) map[string]*dto.MetricFamily {
+	filtered := make(map[string]*dto.MetricFamily, len(metricMap))
+	for name, family := range metricMap {
+		if !containsString(names, name) {
+			filtered[name] = family
+		}
+	}
+	return filtered
+}
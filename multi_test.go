@@ -0,0 +1,136 @@
+package promtest
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// newSnapshotOf takes a snapshot of a registry of its own holding the collectors.
+func newSnapshotOf(t *testing.T, ft *fakeTB, collectors ...prometheus.Collector) *Snapshot {
+	t.Helper()
+	r := NewTestRegistry(ft)
+	r.MustRegister(collectors...)
+	s, err := r.TakeSnapshot()
+	if err != nil {
+		t.Fatalf("Could not take a snapshot: %v", err)
+	}
+	return s
+}
+
+func newBarGauge() prometheus.Collector {
+	g := prometheus.NewGauge(prometheus.GaugeOpts{Name: "bar", Help: "bar"})
+	g.Set(2)
+	return g
+}
+
+func TestMultiSnapshotKeepsSettings(t *testing.T) {
+	ft := &fakeTB{}
+	first := newSnapshotOf(t, ft, newFooCounter()).WithTolerance(0.5).Context("first")
+	m := NewMultiSnapshot(ft, first, newSnapshotOf(t, ft, newBarGauge()))
+	m.AssertCount("foo", map[string]string{"code": "200"}, 1.4)
+	m.AssertGauge("bar", nil, 3)
+	assertSingleFailure(t, ft, "[first] Expected gauge [bar] value to be 3 but was 2")
+}
+
+func TestMultiSnapshotRecordsAssertionsOfEverySnapshot(t *testing.T) {
+	ft := &fakeTB{}
+	first, second := newSnapshotOf(t, ft, newFooCounter()), newSnapshotOf(t, ft, newBarGauge())
+	m := NewMultiSnapshot(ft, first, second)
+	m.AssertCount("foo", map[string]string{"code": "200"}, 1)
+	if names := second.unassertedFamilies(); len(names) != 1 || names[0] != "bar" {
+		t.Errorf("Expected only bar to be unasserted but got %v", names)
+	}
+	m.AssertGauge("bar", nil, 2)
+	if names := append(first.unassertedFamilies(), second.unassertedFamilies()...); len(names) != 0 {
+		t.Errorf("Expected every metric to be asserted but got %v", names)
+	}
+	if len(ft.failures) != 0 {
+		t.Errorf("Expected no failure but got %q", ft.failures)
+	}
+}
+
+func TestMultiSnapshotConflicts(t *testing.T) {
+	tests := []struct {
+		name    string
+		second  func() prometheus.Collector
+		failure string
+	}{
+		{"type", newFooGauge, `[first] Snapshot 1 defines foo as a GAUGE with the help "foo", conflicting with a COUNTER`},
+		{"duplicate series", newFooCounter, `[first] Snapshot 1 duplicates the series of foo with the labels map[code:200]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ft := &fakeTB{}
+			NewMultiSnapshot(ft, newSnapshotOf(t, ft, newFooCounter()).Context("first"), newSnapshotOf(t, ft, tt.second()))
+			assertSingleFailure(t, ft, tt.failure)
+		})
+	}
+}
+
+func TestMergeSnapshots(t *testing.T) {
+	ft := &fakeTB{}
+	a := newSnapshotOf(t, ft, newFooCounter(), newBarGauge()).Context("merged")
+	b := newSnapshotOf(t, ft, newFooCounter())
+	merged := MergeSnapshots(a, b)
+	merged.AssertSeriesCount("foo", 1)
+	merged.AssertGauge("bar", nil, 3)
+	assertSingleFailure(t, ft, "[merged] Expected gauge [bar] value to be 3 but was 2")
+	if names := a.unassertedFamilies(); len(names) != 0 {
+		t.Errorf("Expected the assertions on the merged snapshot to be recorded by a but got %v", names)
+	}
+}
+
+func TestMergeSnapshotsPrefixNames(t *testing.T) {
+	ft := &fakeTB{}
+	a, b := newSnapshotOf(t, ft, newFooCounter()), newSnapshotOf(t, ft, newFooCounter())
+	merged := MergeSnapshots(a, b, PrefixNames("a_", "b_"))
+	merged.AssertCount("a_foo", map[string]string{"code": "200"}, 1)
+	merged.AssertCount("b_foo", map[string]string{"code": "200"}, 1)
+	merged.AssertMetricAbsent("foo")
+	if len(ft.failures) != 0 {
+		t.Errorf("Expected no failure but got %q", ft.failures)
+	}
+}
+
+func TestMergeSnapshotsConflicts(t *testing.T) {
+	otherHelp := func() prometheus.Collector {
+		c := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "foo", Help: "other"}, []string{"code"})
+		c.WithLabelValues("500").Inc()
+		return c
+	}
+	tests := []struct {
+		name    string
+		b       func() prometheus.Collector
+		opts    []MergeOption
+		failure string
+	}{
+		{"type", newFooGauge, nil, "Cannot merge foo, it is a COUNTER in the first snapshot and a GAUGE in the second"},
+		{"help", otherHelp, nil, `Cannot merge foo, its help is "foo" in the first snapshot and "other" in the second`},
+		{"FailOnDuplicate", newFooCounter, []MergeOption{FailOnDuplicate()},
+			"Both snapshots have the series of foo with the labels map[code:200]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ft := &fakeTB{}
+			MergeSnapshots(newSnapshotOf(t, ft, newFooCounter()), newSnapshotOf(t, ft, tt.b()), tt.opts...)
+			assertSingleFailure(t, ft, tt.failure)
+		})
+	}
+}
+
+func TestAssertEquals(t *testing.T) {
+	ft := &fakeTB{}
+	legacy := newSnapshotOf(t, ft, newFooCounter(), newBarGauge())
+	refactored := newSnapshotOf(t, ft, newFooCounter())
+	refactored.AssertEquals(legacy, "bar")
+	if len(ft.failures) != 0 {
+		t.Fatalf("Expected the ignored metric family to be left out but got %q", ft.failures)
+	}
+
+	refactored.AssertEquals(legacy)
+	assertSingleFailure(t, ft, `Metrics differ from the other snapshot (-other +actual):
+- # HELP bar bar
+- # TYPE bar gauge
+- bar 2`)
+}
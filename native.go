@@ -0,0 +1,159 @@
+package promtest
+
+import (
+	"math"
+	"sort"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// AssertNativeHistogramCount asserts the number of observations counted by the buckets of a native
+// histogram in the snapshot, including its zero bucket. Unlike the sample count, the buckets do not
+// count NaN observations.
+func (s *Snapshot) AssertNativeHistogramCount(name string, labels map[string]string, count uint64) {
+	s.t.Helper()
+	histogram, ok := s.nativeHistogram(name, labels)
+	if !ok {
+		return
+	}
+
+	actualCount := histogram.GetZeroCount() + spanCount(histogram.GetPositiveDelta()) +
+		spanCount(histogram.GetNegativeDelta())
+	if actualCount != count {
+		s.errorf(name, "Expected native histogram [%s] buckets to count %d observations but was %d",
+			name, count, actualCount)
+	}
+}
+
+// AssertNativeHistogramZeroCount asserts the number of observations in the zero bucket of a native
+// histogram in the snapshot, i.e. the observations whose absolute value is at most the zero
+// threshold.
+func (s *Snapshot) AssertNativeHistogramZeroCount(name string, labels map[string]string, count uint64) {
+	s.t.Helper()
+	histogram, ok := s.nativeHistogram(name, labels)
+	if !ok {
+		return
+	}
+
+	if actualCount := histogram.GetZeroCount(); actualCount != count {
+		s.errorf(name, "Expected native histogram [%s] zero bucket to count %d observations but was %d",
+			name, count, actualCount)
+	}
+}
+
+// AssertNativeHistogramSum asserts the sample sum of a native histogram in the snapshot.
+func (s *Snapshot) AssertNativeHistogramSum(name string, labels map[string]string, sum float64) {
+	s.t.Helper()
+	histogram, ok := s.nativeHistogram(name, labels)
+	if !ok {
+		return
+	}
+
+	if actualSum := histogram.GetSampleSum(); !s.floatEquals(actualSum, sum) {
+		s.report(name, &ValueMismatchError{dto.MetricType_HISTOGRAM, name, labels, "sample sum", sum, actualSum})
+	}
+}
+
+// AssertNativeHistogramBucketContaining asserts the number of observations in the bucket of a
+// native histogram in the snapshot that an observation of value falls into, given the schema and
+// zero threshold of the histogram.
+func (s *Snapshot) AssertNativeHistogramBucketContaining(name string, labels map[string]string, value float64,
+	count uint64) {
+	s.t.Helper()
+	histogram, ok := s.nativeHistogram(name, labels)
+	if !ok {
+		return
+	}
+
+	var actualCount uint64
+	switch {
+	case math.Abs(value) <= histogram.GetZeroThreshold():
+		actualCount = histogram.GetZeroCount()
+	case value > 0:
+		actualCount = bucketCount(histogram.GetPositiveSpan(), histogram.GetPositiveDelta(),
+			nativeBucketIndex(histogram.GetSchema(), value))
+	default:
+		actualCount = bucketCount(histogram.GetNegativeSpan(), histogram.GetNegativeDelta(),
+			nativeBucketIndex(histogram.GetSchema(), value))
+	}
+	if actualCount != count {
+		s.errorf(name, "Expected native histogram [%s] bucket containing %f to count %d observations but was %d",
+			name, value, count, actualCount)
+	}
+}
+
+// nativeHistogram returns a native histogram, failing the test if there is no such histogram or it
+// only has classic buckets.
+func (s *Snapshot) nativeHistogram(name string, labels map[string]string) (*dto.Histogram, bool) {
+	s.t.Helper()
+	metric, err := s.findMetric(dto.MetricType_HISTOGRAM, name, labels)
+	if err != nil {
+		s.report(name, err)
+		return nil, false
+	}
+
+	histogram := metric.GetHistogram()
+	if !isNativeHistogram(histogram) {
+		s.errorf(name, "Histogram %s with the labels %v is not a native histogram, its upper bounds are %s",
+			name, labels, formatUpperBounds(histogram))
+		return nil, false
+	}
+	return histogram, true
+}
+
+// isNativeHistogram reports whether a histogram has native buckets. Native histograms without
+// observations still carry a zero threshold or an empty span.
+func isNativeHistogram(histogram *dto.Histogram) bool {
+	return histogram.GetSchema() != 0 || histogram.GetZeroThreshold() > 0 || histogram.GetZeroCount() > 0 ||
+		len(histogram.GetPositiveSpan()) > 0 || len(histogram.GetNegativeSpan()) > 0
+}
+
+// nativeBucketIndex returns the index of the native bucket an observation of value falls into, the
+// bucket with the index i covering (base^(i-1), base^i] for a base of 2^(2^-schema).
+func nativeBucketIndex(schema int32, value float64) int32 {
+	frac, exp := math.Frexp(math.Abs(value))
+	if schema > 0 {
+		n := 1 << uint(schema)
+		bounds := make([]float64, n)
+		for i := range bounds {
+			bounds[i] = math.Exp2(float64(i)/float64(n) - 1)
+		}
+		return int32(sort.SearchFloat64s(bounds, frac) + (exp-1)*n)
+	}
+	if frac == 0.5 {
+		exp--
+	}
+	offset := (1 << uint(-schema)) - 1
+	return int32((exp + offset) >> uint(-schema))
+}
+
+// bucketCount returns the number of observations in the native bucket with the given index, given
+// the spans of the populated buckets and their delta encoded counts.
+func bucketCount(spans []*dto.BucketSpan, deltas []int64, index int32) uint64 {
+	var count int64
+	var bucket int
+	var current int32
+	for _, span := range spans {
+		current += span.GetOffset()
+		for i := uint32(0); i < span.GetLength() && bucket < len(deltas); i++ {
+			count += deltas[bucket]
+			bucket++
+			if current == index {
+				return uint64(count)
+			}
+			current++
+		}
+	}
+	return 0
+}
+
+// spanCount returns the number of observations in the buckets of delta encoded bucket counts,
+// where each delta is relative to the count of the previous bucket.
+func spanCount(deltas []int64) uint64 {
+	var count, total int64
+	for _, delta := range deltas {
+		count += delta
+		total += count
+	}
+	return uint64(total)
+}
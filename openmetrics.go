@@ -0,0 +1,406 @@
+package promtest
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// openMetricsSuffixes lists the suffixes of the sample names of each OpenMetrics metric type.
+var openMetricsSuffixes = map[string][]string{
+	"counter":        {"_total", "_created"},
+	"gauge":          {""},
+	"histogram":      {"_bucket", "_count", "_sum", "_created"},
+	"gaugehistogram": {"_bucket", "_gcount", "_gsum"},
+	"summary":        {"", "_count", "_sum", "_created"},
+	"info":           {"_info"},
+	"stateset":       {""},
+	"unknown":        {""},
+}
+
+// openMetricsTypes maps OpenMetrics metric types to the metric types of the gathered metric
+// families. Info and stateset metrics are gauges, as client_golang exposes them.
+var openMetricsTypes = map[string]dto.MetricType{
+	"counter":        dto.MetricType_COUNTER,
+	"gauge":          dto.MetricType_GAUGE,
+	"histogram":      dto.MetricType_HISTOGRAM,
+	"gaugehistogram": dto.MetricType_GAUGE_HISTOGRAM,
+	"summary":        dto.MetricType_SUMMARY,
+	"info":           dto.MetricType_GAUGE,
+	"stateset":       dto.MetricType_GAUGE,
+	"unknown":        dto.MetricType_UNTYPED,
+}
+
+// isOpenMetrics reports whether an exposition is in the OpenMetrics text format, which unlike the
+// Prometheus text format ends with a # EOF line.
+func isOpenMetrics(data []byte) bool {
+	data = bytes.TrimRight(data, "\n")
+	return bytes.Equal(data, []byte("# EOF")) || bytes.HasSuffix(data, []byte("\n# EOF"))
+}
+
+// decodeOpenMetrics decodes every metric family of an exposition in the OpenMetrics text format,
+// which expfmt cannot decode, keeping the exemplars of counters and histogram buckets. Metric
+// families are named like the gathered ones, e.g. a counter foo is called foo_total. Created
+// timestamps are dropped.
+func decodeOpenMetrics(r io.Reader) ([]*dto.MetricFamily, error) {
+	p := &openMetricsParser{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if err := p.parseLine(scanner.Text()); err != nil {
+			return nil, fmt.Errorf("could not parse metrics: line %d: %v", line, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("could not parse metrics: %v", err)
+	}
+	if !p.eof {
+		return nil, errors.New("could not parse metrics: missing # EOF")
+	}
+	return p.families, nil
+}
+
+// openMetricsParser holds the state of decodeOpenMetrics. The samples of a metric family follow its
+// metadata and those of a series are consecutive.
+type openMetricsParser struct {
+	families []*dto.MetricFamily
+	eof      bool
+
+	// The metric family being parsed, by its OpenMetrics name and type
+	family     *dto.MetricFamily
+	name       string
+	metricType string
+	series     map[string]*dto.Metric
+}
+
+func (p *openMetricsParser) parseLine(line string) error {
+	switch {
+	case p.eof:
+		return errors.New("unexpected content after # EOF")
+	case line == "# EOF":
+		p.eof = true
+		return nil
+	case strings.HasPrefix(line, "#"):
+		return p.parseMetadata(line)
+	}
+	return p.parseSample(line)
+}
+
+// parseMetadata parses a # TYPE, # HELP or # UNIT line, starting a new metric family if it names
+// another one than the family being parsed.
+func (p *openMetricsParser) parseMetadata(line string) error {
+	fields := strings.SplitN(line, " ", 4)
+	if len(fields) < 3 || fields[0] != "#" {
+		return fmt.Errorf("unexpected comment %q", line)
+	}
+	keyword, name := fields[1], fields[2]
+	value := ""
+	if len(fields) == 4 {
+		value = fields[3]
+	}
+	if p.family == nil || name != p.name {
+		p.startFamily(name, "unknown")
+	} else if len(p.family.Metric) > 0 {
+		return fmt.Errorf("metadata of %s after its samples", name)
+	}
+
+	switch keyword {
+	case "TYPE":
+		if _, ok := openMetricsTypes[value]; !ok {
+			return fmt.Errorf("invalid type %q of %s", value, name)
+		}
+		p.setType(value)
+	case "HELP":
+		help := unescapeOpenMetrics(value)
+		p.family.Help = &help
+	case "UNIT":
+		p.family.Unit = &value
+	default:
+		return fmt.Errorf("unexpected comment %q", line)
+	}
+	return nil
+}
+
+// startFamily starts parsing the metric family with the given OpenMetrics name and type.
+func (p *openMetricsParser) startFamily(name string, metricType string) {
+	p.family = &dto.MetricFamily{Name: &name}
+	p.name = name
+	p.series = make(map[string]*dto.Metric)
+	p.families = append(p.families, p.family)
+	p.setType(metricType)
+}
+
+// setType sets the type of the metric family being parsed, naming it like the gathered one.
+func (p *openMetricsParser) setType(metricType string) {
+	p.metricType = metricType
+	familyType := openMetricsTypes[metricType]
+	p.family.Type = &familyType
+	familyName := p.name
+	switch metricType {
+	case "counter":
+		familyName += "_total"
+	case "info":
+		familyName += "_info"
+	}
+	p.family.Name = &familyName
+}
+
+// parseSample parses a sample line, e.g.
+//
+//	foo_bucket{le="0.5"} 3 1700000000.123 # {trace_id="abc"} 0.42 1700000000.1
+func (p *openMetricsParser) parseSample(line string) error {
+	i := strings.IndexAny(line, "{ ")
+	if i <= 0 {
+		return fmt.Errorf("invalid sample %q", line)
+	}
+	sampleName, rest := line[:i], line[i:]
+	labels := map[string]string{}
+	if strings.HasPrefix(rest, "{") {
+		var err error
+		if labels, rest, err = parseOpenMetricsLabels(rest); err != nil {
+			return err
+		}
+	}
+	if !strings.HasPrefix(rest, " ") {
+		return fmt.Errorf("invalid sample %q", line)
+	}
+
+	var exemplar *dto.Exemplar
+	if i := strings.Index(rest, " # "); i >= 0 {
+		var err error
+		if exemplar, err = parseExemplar(rest[i+len(" # "):]); err != nil {
+			return err
+		}
+		rest = rest[:i]
+	}
+	fields := strings.Split(strings.TrimPrefix(rest, " "), " ")
+	if len(fields) > 2 {
+		return fmt.Errorf("invalid sample %q", line)
+	}
+	value, err := parseOpenMetricsFloat(fields[0])
+	if err != nil {
+		return err
+	}
+	var timestampMs *int64
+	if len(fields) == 2 {
+		timestamp, err := parseOpenMetricsFloat(fields[1])
+		if err != nil {
+			return err
+		}
+		ms := int64(math.Round(timestamp * 1000))
+		timestampMs = &ms
+	}
+
+	suffix, ok := p.sampleSuffix(sampleName)
+	if !ok {
+		// Samples without metadata are of an unknown type
+		p.startFamily(sampleName, "unknown")
+		suffix = ""
+	}
+	return p.addSample(suffix, labels, value, timestampMs, exemplar)
+}
+
+// sampleSuffix returns the suffix of a sample name of the metric family being parsed, or false if
+// the sample belongs to another metric family.
+func (p *openMetricsParser) sampleSuffix(sampleName string) (string, bool) {
+	if p.family == nil {
+		return "", false
+	}
+	for _, suffix := range openMetricsSuffixes[p.metricType] {
+		if sampleName == p.name+suffix {
+			return suffix, true
+		}
+	}
+	return "", false
+}
+
+// addSample adds a sample to its series in the metric family being parsed.
+func (p *openMetricsParser) addSample(suffix string, labels map[string]string, value float64,
+	timestampMs *int64, exemplar *dto.Exemplar) error {
+	if exemplar != nil && !(p.metricType == "counter" && suffix == "_total") && suffix != "_bucket" {
+		return fmt.Errorf("exemplar of %s%s, only counters and histogram buckets have exemplars", p.name, suffix)
+	}
+
+	var bound string
+	switch {
+	case suffix == "_bucket":
+		bound = "le"
+	case p.metricType == "summary" && suffix == "":
+		bound = "quantile"
+	}
+	boundValue, hasBound := labels[bound]
+	if bound != "" {
+		if !hasBound {
+			return fmt.Errorf("sample of %s%s without the label %s", p.name, suffix, bound)
+		}
+		delete(labels, bound)
+	}
+	m := p.seriesOf(labels)
+	if timestampMs != nil {
+		m.TimestampMs = timestampMs
+	}
+
+	switch p.metricType {
+	case "counter":
+		if suffix == "_total" {
+			m.Counter.Value = &value
+			m.Counter.Exemplar = exemplar
+		}
+	case "gauge", "info", "stateset":
+		m.Gauge.Value = &value
+	case "unknown":
+		m.Untyped.Value = &value
+	case "summary":
+		switch suffix {
+		case "":
+			quantile, err := parseOpenMetricsFloat(boundValue)
+			if err != nil {
+				return err
+			}
+			m.Summary.Quantile = append(m.Summary.Quantile, &dto.Quantile{Quantile: &quantile, Value: &value})
+		case "_count":
+			count := uint64(value)
+			m.Summary.SampleCount = &count
+		case "_sum":
+			m.Summary.SampleSum = &value
+		}
+	case "histogram", "gaugehistogram":
+		switch suffix {
+		case "_bucket":
+			upperBound, err := parseOpenMetricsFloat(boundValue)
+			if err != nil {
+				return err
+			}
+			count := uint64(value)
+			m.Histogram.Bucket = append(m.Histogram.Bucket,
+				&dto.Bucket{UpperBound: &upperBound, CumulativeCount: &count, Exemplar: exemplar})
+		case "_count", "_gcount":
+			count := uint64(value)
+			m.Histogram.SampleCount = &count
+		case "_sum", "_gsum":
+			m.Histogram.SampleSum = &value
+		}
+	}
+	return nil
+}
+
+// seriesOf returns the series of the metric family being parsed with the given labels, adding it
+// if it is new.
+func (p *openMetricsParser) seriesOf(labels map[string]string) *dto.Metric {
+	key := formatLabels(labels)
+	if m, ok := p.series[key]; ok {
+		return m
+	}
+	m := &dto.Metric{Label: labelPairs(labels)}
+	switch p.metricType {
+	case "counter":
+		m.Counter = &dto.Counter{}
+	case "gauge", "info", "stateset":
+		m.Gauge = &dto.Gauge{}
+	case "unknown":
+		m.Untyped = &dto.Untyped{}
+	case "summary":
+		m.Summary = &dto.Summary{}
+	case "histogram", "gaugehistogram":
+		m.Histogram = &dto.Histogram{}
+	}
+	p.series[key] = m
+	p.family.Metric = append(p.family.Metric, m)
+	return m
+}
+
+// parseExemplar parses the exemplar of a sample, e.g. {trace_id="abc"} 0.42 1700000000.1
+func parseExemplar(s string) (*dto.Exemplar, error) {
+	if !strings.HasPrefix(s, "{") {
+		return nil, fmt.Errorf("invalid exemplar %q", s)
+	}
+	labels, rest, err := parseOpenMetricsLabels(s)
+	if err != nil {
+		return nil, err
+	}
+	fields := strings.Split(strings.TrimPrefix(rest, " "), " ")
+	if !strings.HasPrefix(rest, " ") || len(fields) > 2 {
+		return nil, fmt.Errorf("invalid exemplar %q", s)
+	}
+	value, err := parseOpenMetricsFloat(fields[0])
+	if err != nil {
+		return nil, err
+	}
+	exemplar := &dto.Exemplar{Label: labelPairs(labels), Value: &value}
+	if len(fields) == 2 {
+		timestamp, err := parseOpenMetricsFloat(fields[1])
+		if err != nil {
+			return nil, err
+		}
+		sec, frac := math.Modf(timestamp)
+		exemplar.Timestamp = timestamppb.New(time.Unix(int64(sec), int64(math.Round(frac*1e9))))
+	}
+	return exemplar, nil
+}
+
+// parseOpenMetricsLabels parses the labels at the start of s, e.g. {code="200",method="GET"},
+// returning the rest of s.
+func parseOpenMetricsLabels(s string) (map[string]string, string, error) {
+	labels := make(map[string]string)
+	rest := strings.TrimPrefix(s, "{")
+	for !strings.HasPrefix(rest, "}") {
+		i := strings.Index(rest, `="`)
+		if i <= 0 {
+			return nil, "", fmt.Errorf("invalid labels %q", s)
+		}
+		name := rest[:i]
+		rest = rest[i+len(`="`):]
+
+		var value strings.Builder
+		for {
+			if rest == "" {
+				return nil, "", fmt.Errorf("unterminated label value in %q", s)
+			}
+			c := rest[0]
+			rest = rest[1:]
+			if c == '"' {
+				break
+			}
+			if c == '\\' && rest != "" {
+				c, rest = rest[0], rest[1:]
+				if c == 'n' {
+					c = '\n'
+				}
+			}
+			value.WriteByte(c)
+		}
+		if _, ok := labels[name]; ok {
+			return nil, "", fmt.Errorf("duplicate label %s in %q", name, s)
+		}
+		labels[name] = value.String()
+		if strings.HasPrefix(rest, ",") {
+			rest = rest[1:]
+		} else if !strings.HasPrefix(rest, "}") {
+			return nil, "", fmt.Errorf("invalid labels %q", s)
+		}
+	}
+	return labels, rest[1:], nil
+}
+
+// parseOpenMetricsFloat parses a value or timestamp, including +Inf, -Inf and NaN.
+func parseOpenMetricsFloat(s string) (float64, error) {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q", s)
+	}
+	return f, nil
+}
+
+// unescapeOpenMetrics unescapes the \\, \" and \n escapes of a help text.
+func unescapeOpenMetrics(s string) string {
+	return strings.NewReplacer(`\\`, `\`, `\"`, `"`, `\n`, "\n").Replace(s)
+}
@@ -0,0 +1,98 @@
+package promtest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/common/expfmt"
+)
+
+const openMetricsExposition = `# HELP http_requests Requests handled.
+# TYPE http_requests counter
+http_requests_total{code="200"} 3 # {trace_id="abc"} 1 1700000000.5
+http_requests_created{code="200"} 1700000000
+# HELP latency_seconds Request latency.
+# TYPE latency_seconds histogram
+# UNIT latency_seconds seconds
+latency_seconds_bucket{le="0.1"} 1 # {trace_id="def"} 0.05
+latency_seconds_bucket{le="1"} 2
+latency_seconds_bucket{le="+Inf"} 2
+latency_seconds_count 2
+latency_seconds_sum 0.55
+# TYPE temperature gauge
+temperature{room="a \"b\""} -3.5
+# EOF
+`
+
+func TestNewSnapshotFromReaderOpenMetrics(t *testing.T) {
+	ft := &fakeTB{}
+	s, err := NewSnapshotFromReader(ft, strings.NewReader(openMetricsExposition))
+	if err != nil {
+		t.Fatalf("Could not parse the exposition: %v", err)
+	}
+	s.AssertCount("http_requests_total", map[string]string{"code": "200"}, 3)
+	s.AssertCounterExemplar("http_requests_total", map[string]string{"code": "200"}, map[string]string{"trace_id": "abc"})
+	s.AssertCounterExemplarValue("http_requests_total", map[string]string{"code": "200"}, 1)
+	s.AssertHistogram("latency_seconds", nil, 0.55, 2)
+	s.AssertHistogramBucket("latency_seconds", nil, 1, 2)
+	s.AssertHistogramBucketExemplar("latency_seconds", nil, 0.1, map[string]string{"trace_id": "def"})
+	s.AssertHistogramBucketExemplarValue("latency_seconds", nil, 0.1, 0.05)
+	s.AssertGauge("temperature", map[string]string{"room": `a "b"`}, -3.5)
+	if len(ft.failures) != 0 {
+		t.Errorf("Expected no failure but got %q", ft.failures)
+	}
+}
+
+func TestNewSnapshotFromReaderOpenMetricsErrors(t *testing.T) {
+	tests := map[string]string{
+		"missing # EOF":       "# TYPE foo gauge\nfoo 1\n",
+		"content after # EOF": "# TYPE foo gauge\nfoo 1\n# EOF\nfoo 2\n# EOF\n",
+		"exemplar of a gauge": "# TYPE foo gauge\nfoo 1 # {trace_id=\"abc\"} 1\n# EOF\n",
+		"bucket without le":   "# TYPE foo histogram\nfoo_bucket 1\n# EOF\n",
+		"invalid value":       "# TYPE foo gauge\nfoo one\n# EOF\n",
+	}
+	for name, exposition := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := decodeOpenMetrics(strings.NewReader(exposition)); err == nil {
+				t.Errorf("Expected an error parsing %q", exposition)
+			}
+		})
+	}
+}
+
+func TestNewSnapshotFromResponseOpenMetrics(t *testing.T) {
+	reg := prometheus.NewPedanticRegistry()
+	c := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "http_requests_total", Help: "Requests handled."},
+		[]string{"code"})
+	reg.MustRegister(c)
+	c.WithLabelValues("200").(prometheus.ExemplarAdder).AddWithExemplar(2, prometheus.Labels{"trace_id": "abc"})
+
+	// The handler serves protobuf to NewSnapshotFromHandler, so only OpenMetrics is accepted
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.Header.Set("Accept", string(expfmt.NewFormat(expfmt.TypeOpenMetrics)))
+	rec := httptest.NewRecorder()
+	promhttp.HandlerFor(reg, promhttp.HandlerOpts{EnableOpenMetrics: true}).ServeHTTP(rec, req)
+	if contentType := rec.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "application/openmetrics-text") {
+		t.Fatalf("Expected OpenMetrics to be served but was %s", contentType)
+	}
+
+	ft := &fakeTB{}
+	s, err := newSnapshotFromResponse(ft, rec.Result())
+	if err != nil {
+		t.Fatalf("Could not scrape the handler: %v", err)
+	}
+	s.AssertCount("http_requests_total", map[string]string{"code": "200"}, 2)
+	s.AssertCounterExemplar("http_requests_total", map[string]string{"code": "200"}, map[string]string{"trace_id": "abc"})
+	s.AssertExposition(`# HELP http_requests Requests handled.
+# TYPE http_requests counter
+http_requests_total{code="200"} 2
+# EOF
+`)
+	if len(ft.failures) != 0 {
+		t.Errorf("Expected no failure but got %q", ft.failures)
+	}
+}
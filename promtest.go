@@ -1,172 +1,840 @@
 package promtest
 
 import (
+	"errors"
+	"fmt"
+	"io"
 	"math"
-	"testing"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
 	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
 )
 
+// TB reports assertion failures. It is implemented by testing.TB, and by the T of other test
+// frameworks such as GinkgoT(), or can be implemented to run assertions outside of tests.
+type TB interface {
+	Helper()
+	Error(args ...interface{})
+	Errorf(format string, args ...interface{})
+	Fatal(args ...interface{})
+	Fatalf(format string, args ...interface{})
+}
+
+// cleanupTB is a TB that can run functions when the test ends, like testing.TB.
+type cleanupTB interface {
+	TB
+	Cleanup(fn func())
+	Failed() bool
+	Logf(format string, args ...interface{})
+}
+
 // TestRegistry is a prometheus registry meant to be used for testing
 type TestRegistry struct {
 	*prometheus.Registry
-	t *testing.T
+	t         TB
+	tolerance float64
+	strict    bool
+	asserted  *assertedFamilies
+	lint      bool
+	lintRules []LintRule
+	dump      bool
+	clock     Clock
+
+	snapshotOpts []SnapshotOption
+	invocations  *collectorInvocations
+	recorders    *recorders
+}
+
+// Option configures a TestRegistry
+type Option func(r *TestRegistry)
+
+// WithTolerance sets the tolerance within which the snapshots of the registry consider two values
+// equal. The default tolerance is 1e-8, a tolerance of 0 requires values to be exactly equal.
+func WithTolerance(epsilon float64) Option {
+	return func(r *TestRegistry) {
+		r.tolerance = epsilon
+	}
+}
+
+// DumpOnFailure makes a failed test log every metric of the registry when it ends. It requires the
+// TB of the registry to implement Cleanup, Failed and Logf like testing.TB, or the test fails.
+func DumpOnFailure() Option {
+	return func(r *TestRegistry) {
+		r.dump = true
+	}
 }
 
 // NewTestRegistry allocates and initializes a new TestRegistry
-func NewTestRegistry(t *testing.T) *TestRegistry {
-	return &TestRegistry{
-		Registry: prometheus.NewPedanticRegistry(),
-		t:        t,
+func NewTestRegistry(t TB, opts ...Option) *TestRegistry {
+	r := &TestRegistry{
+		Registry:  prometheus.NewPedanticRegistry(),
+		t:         t,
+		tolerance: defaultTolerance,
+		asserted:  newAssertedFamilies(),
+
+		invocations: newCollectorInvocations(),
+		recorders:   &recorders{},
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	ct, ok := t.(cleanupTB)
+	if !ok {
+		if r.strict || r.dump {
+			t.Errorf("Strict and DumpOnFailure require a TB implementing Cleanup, Failed and Logf like testing.TB, %T does not", t)
+		}
+		return r
+	}
+	if r.strict {
+		ct.Cleanup(r.assertNoUnassertedMetrics)
 	}
+	if r.dump {
+		ct.Cleanup(r.dumpOnFailure)
+	}
+	return r
+}
+
+// NewTestRegistryWithDefaults allocates and initializes a new TestRegistry with the Go and process
+// collectors registered, like the default prometheus registry. Their go_* and process_* metrics
+// are ignored by AssertNoMetrics and AssertMatches.
+func NewTestRegistryWithDefaults(t TB, opts ...Option) *TestRegistry {
+	r := NewTestRegistry(t, opts...)
+	r.MustRegister(collectors.NewGoCollector(), collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+	return r
 }
 
-// TakeSnapshot takes a snapshot of the current values of metrics for testing
-func (r *TestRegistry) TakeSnapshot() (*Snapshot, error) {
-	metrics, err := r.Registry.Gather()
+// dumpOnFailure logs every metric of the registry if the test failed.
+func (r *TestRegistry) dumpOnFailure() {
+	t := r.t.(cleanupTB)
+	if !t.Failed() {
+		return
+	}
+	s, err := NewSnapshotFromGatherer(r.t, r.Registry)
 	if err != nil {
+		t.Logf("Could not gather metrics to dump: %v", err)
+		return
+	}
+	t.Logf("Metrics of the registry:\n%s", s)
+}
+
+// ForSubtest returns a view of the registry whose failures are reported to t, e.g. a parallel
+// subtest sharing the registry of its parent. The prometheus registry is safe for concurrent use,
+// so views can register collectors and take snapshots concurrently.
+func (r *TestRegistry) ForSubtest(t TB) *TestRegistry {
+	c := *r
+	c.t = t
+	return &c
+}
+
+// TakeSnapshot takes a snapshot of the current values of metrics for testing, rewritten by the
+// snapshot options of the registry and opts, if any.
+func (r *TestRegistry) TakeSnapshot(opts ...SnapshotOption) (*Snapshot, error) {
+	r.t.Helper()
+	return r.takeSnapshot(r.lint, opts)
+}
+
+// takeSnapshot takes a snapshot of the registry, linting the gathered metrics if lint is set.
+func (r *TestRegistry) takeSnapshot(lint bool, opts []SnapshotOption) (*Snapshot, error) {
+	r.t.Helper()
+	s, err := NewSnapshotFromGatherer(r.t, r.Registry)
+	if err != nil {
+		return nil, err
+	}
+	if lint {
+		families := make([]*dto.MetricFamily, 0, len(s.MetricMap))
+		for _, name := range sortedNames(s.MetricMap) {
+			families = append(families, s.MetricMap[name])
+		}
+		r.assertLintClean(families)
+	}
+	if s.MetricMap, err = r.rewrite(s.MetricMap, opts); err != nil {
 		return nil, err
 	}
-	metricMap := make(map[string]*dto.MetricFamily)
-	for _, metric := range metrics {
-		metricMap[metric.GetName()] = metric
+	return r.configure(s), nil
+}
+
+// configure makes a snapshot of the registry carry its options.
+func (r *TestRegistry) configure(s *Snapshot) *Snapshot {
+	if r.clock != nil {
+		s.takenAt = r.clock.Now()
 	}
-	return &Snapshot{metricMap, r.t}, nil
+	s.tolerance = r.tolerance
+	s.asserted = r.asserted
+	return s
 }
 
 // Snapshot provides methods for asserting on metrics
 type Snapshot struct {
 	MetricMap map[string]*dto.MetricFamily
-	t         *testing.T
+	t         TB
+	takenAt   time.Time
+	prefix    string
+	context   string
+	verbose   bool
+	fatal     bool
+	tolerance float64
+	asserted  *assertedFamilies
+	matchers  []LabelMatcher
+
+	floatFormat func(float64) string
+}
+
+// NewSnapshotFromFamilies creates a snapshot of already gathered metric families. The series of
+// families sharing a name are merged into a single family.
+func NewSnapshotFromFamilies(t TB, families []*dto.MetricFamily) *Snapshot {
+	metricMap := make(map[string]*dto.MetricFamily, len(families))
+	for _, family := range families {
+		name := family.GetName()
+		existing, ok := metricMap[name]
+		if !ok {
+			metricMap[name] = family
+			continue
+		}
+		metricMap[name] = &dto.MetricFamily{
+			Name:   existing.Name,
+			Help:   existing.Help,
+			Type:   existing.Type,
+			Unit:   existing.Unit,
+			Metric: append(append([]*dto.Metric(nil), existing.GetMetric()...), family.GetMetric()...),
+		}
+	}
+	return &Snapshot{
+		MetricMap: metricMap,
+		t:         t,
+		takenAt:   time.Now(),
+		tolerance: defaultTolerance,
+		asserted:  newAssertedFamilies(),
+	}
+}
+
+// NewSnapshotFromGatherer gathers g and creates a snapshot of its metrics, e.g. of
+// prometheus.DefaultGatherer or of a registry owned by a library.
+func NewSnapshotFromGatherer(t TB, g prometheus.Gatherer) (*Snapshot, error) {
+	families, err := g.Gather()
+	if err != nil {
+		return nil, err
+	}
+	return NewSnapshotFromFamilies(t, families), nil
+}
+
+// TakenAt returns the time at which the snapshot was taken
+func (s *Snapshot) TakenAt() time.Time {
+	return s.takenAt
+}
+
+// Only returns a copy of the snapshot containing only the named metric families.
+func (s *Snapshot) Only(names ...string) *Snapshot {
+	c := *s
+	c.MetricMap = make(map[string]*dto.MetricFamily, len(names))
+	for _, name := range names {
+		if family, ok := s.lookup(name); ok {
+			c.MetricMap[family.GetName()] = family
+		}
+	}
+	return &c
+}
+
+// WithVerboseFailures returns a copy of the snapshot whose failed assertions include the full
+// state of the metric family involved in the failure.
+func (s *Snapshot) WithVerboseFailures() *Snapshot {
+	c := *s
+	c.verbose = true
+	return &c
+}
+
+// Require returns a copy of the snapshot whose failed assertions stop the test with t.Fatal
+// instead of reporting the failure with t.Error and carrying on.
+func (s *Snapshot) Require() *Snapshot {
+	c := *s
+	c.fatal = true
+	return &c
+}
+
+// WithFloatFormat returns a copy of the snapshot whose failed assertions, String, golden files and
+// diffs render sample values with format, e.g. to round them to two decimals. By default values are
+// rendered without trailing zeros.
+func (s *Snapshot) WithFloatFormat(format func(float64) string) *Snapshot {
+	c := *s
+	c.floatFormat = format
+	return &c
+}
+
+// WithTolerance returns a copy of the snapshot whose assertions consider two values equal when they
+// differ by less than epsilon.
+func (s *Snapshot) WithTolerance(epsilon float64) *Snapshot {
+	c := *s
+	c.tolerance = epsilon
+	return &c
+}
+
+// Context returns a copy of the snapshot whose failed assertions are prefixed with [name], e.g. the
+// name of a table test case. Contexts of nested calls are joined with a slash.
+func (s *Snapshot) Context(name string) *Snapshot {
+	c := *s
+	if c.context != "" {
+		name = c.context + "/" + name
+	}
+	c.context = name
+	return &c
+}
+
+// WithNamePrefix returns a copy of the snapshot whose assertions prepend prefix to the names of the
+// metrics they look up, e.g. for metrics registered through prometheus.WrapRegistererWithPrefix.
+// The prefix replaces the prefix of the snapshot, if any. Constant labels added by a wrapping
+// registerer are not affected and still need to be part of the asserted labels.
+func (s *Snapshot) WithNamePrefix(prefix string) *Snapshot {
+	c := *s
+	c.prefix = prefix
+	return &c
 }
 
-// AssertCount asserts existence and count of a counter in the snapshot.
-func (s *Snapshot) AssertCount(name string, labels map[string]string, value float64) {
+// AssertCount asserts existence and count of a counter in the snapshot. Comparisons, if any, replace
+// the equality of the count.
+func (s *Snapshot) AssertCount(name string, labels map[string]string, value float64, cmp ...Comparison) {
 	s.t.Helper()
-	metric := s.GetMetric(dto.MetricType_COUNTER, name, labels)
+	s.report(name, s.CheckCount(name, labels, value, cmp...))
+}
 
-	if metric == nil {
-		if value == 0 {
+// CheckCount is like AssertCount but returns the failure as an error instead of failing the test.
+func (s *Snapshot) CheckCount(name string, labels map[string]string, value float64, cmp ...Comparison) error {
+	metric, err := s.findMetric(dto.MetricType_COUNTER, name, labels)
+	if err != nil {
+		if isNotFound(err) && s.satisfies(value, 0, cmp) {
 			// Counter not existing is the same as the counter having 0 value
+			return nil
+		}
+		return err
+	}
+
+	return s.compareValue(dto.MetricType_COUNTER, name, labels, "value", value, metric.GetCounter().GetValue(), cmp)
+}
+
+// AssertGauge asserts existence and value of a gauge in the snapshot. Comparisons, if any, replace
+// the equality of the value.
+func (s *Snapshot) AssertGauge(name string, labels map[string]string, value float64, cmp ...Comparison) {
+	s.t.Helper()
+	s.report(name, s.CheckGauge(name, labels, value, cmp...))
+}
+
+// CheckGauge is like AssertGauge but returns the failure as an error instead of failing the test.
+func (s *Snapshot) CheckGauge(name string, labels map[string]string, value float64, cmp ...Comparison) error {
+	metric, err := s.findMetric(dto.MetricType_GAUGE, name, labels)
+	if err != nil {
+		if isNotFound(err) && s.satisfies(value, 0, cmp) {
+			// Gauge not existing is the same as the gauge having 0 value
+			return nil
+		}
+		return err
+	}
+
+	return s.compareValue(dto.MetricType_GAUGE, name, labels, "value", value, metric.GetGauge().GetValue(), cmp)
+}
+
+// AssertCounterEqualsInt asserts existence and count of a counter in the snapshot, e.g. to compare
+// it with the length of a slice.
+func (s *Snapshot) AssertCounterEqualsInt(name string, labels map[string]string, expected int) {
+	s.t.Helper()
+	s.report(name, s.CheckCount(name, labels, float64(expected)))
+}
+
+// AssertGaugeEqualsInt asserts existence and value of a gauge in the snapshot, e.g. to compare it
+// with the length of a slice.
+func (s *Snapshot) AssertGaugeEqualsInt(name string, labels map[string]string, expected int) {
+	s.t.Helper()
+	s.report(name, s.CheckGauge(name, labels, float64(expected)))
+}
+
+// AssertCounterIsInteger asserts that the value of a counter in the snapshot is a whole number.
+func (s *Snapshot) AssertCounterIsInteger(name string, labels map[string]string) {
+	s.t.Helper()
+	if err := s.typeError(dto.MetricType_COUNTER, name); err != nil {
+		s.report(name, err)
+		return
+	}
+	// Counter not existing is the same as the counter having 0 value
+	if metric := s.GetMetric(dto.MetricType_COUNTER, name, labels); metric != nil {
+		if actualValue := metric.GetCounter().GetValue(); !s.floatEquals(actualValue, math.Round(actualValue)) {
+			s.errorf(name, "Expected counter [%s] value to be an integer but was %f", name, actualValue)
+		}
+	}
+}
+
+// AssertGaugeIsInteger asserts that the value of a gauge in the snapshot is a whole number.
+func (s *Snapshot) AssertGaugeIsInteger(name string, labels map[string]string) {
+	s.t.Helper()
+	if err := s.typeError(dto.MetricType_GAUGE, name); err != nil {
+		s.report(name, err)
+		return
+	}
+	// Gauge not existing is the same as the gauge having 0 value
+	if metric := s.GetMetric(dto.MetricType_GAUGE, name, labels); metric != nil {
+		if actualValue := metric.GetGauge().GetValue(); !s.floatEquals(actualValue, math.Round(actualValue)) {
+			s.errorf(name, "Expected gauge [%s] value to be an integer but was %f", name, actualValue)
+		}
+	}
+}
+
+// AssertGaugeBoundedAcross asserts that the value of a gauge stays within [min, max] in this
+// snapshot and in each of the given snapshots. Only the first out of band reading is reported,
+// with reading 0 being this snapshot and reading i being snapshots[i-1].
+func (s *Snapshot) AssertGaugeBoundedAcross(name string, labels map[string]string, min, max float64,
+	snapshots ...*Snapshot) {
+	s.t.Helper()
+	for i, snapshot := range append([]*Snapshot{s}, snapshots...) {
+		var actualValue float64
+		metric, err := snapshot.findMetric(dto.MetricType_GAUGE, name, labels)
+		if err != nil && (!isNotFound(err) || min > 0 || max < 0) {
+			s.errorf(name, "Reading %d: %s", i, s.renderError(err))
+			return
+		}
+		if metric != nil {
+			actualValue = metric.GetGauge().GetValue()
+		}
+
+		if actualValue < min || actualValue > max {
+			s.errorf(name, "Expected gauge [%s] value to be in [%f, %f] but was %f in reading %d",
+				name, min, max, actualValue, i)
 			return
 		}
-		s.t.Errorf("Could not find Counter %s with the labels %v", name, labels)
 	}
+}
+
+// AssertGaugeReadings passes the values of a gauge in each of the snapshots, in order, to assert.
+// A snapshot without the gauge fails the test and contributes no value, rather than a value of 0.
+func AssertGaugeReadings(name string, labels map[string]string, snapshots []*Snapshot, assert func(values []float64)) {
+	values := make([]float64, 0, len(snapshots))
+	for i, snapshot := range snapshots {
+		snapshot.t.Helper()
+		metric, err := snapshot.findMetric(dto.MetricType_GAUGE, name, labels)
+		if err != nil {
+			snapshot.errorf(name, "Reading %d: %s", i, err)
+			continue
+		}
+		values = append(values, metric.GetGauge().GetValue())
+	}
+	assert(values)
+}
+
+// AssertGaugeVecAllUnder asserts that no series of a gauge family in the snapshot exceeds max. The
+// offending series are reported worst first.
+func (s *Snapshot) AssertGaugeVecAllUnder(name string, max float64) {
+	s.t.Helper()
+	if err := s.typeError(dto.MetricType_GAUGE, name); err != nil {
+		s.report(name, err)
+		return
+	}
+
+	family, _ := s.family(name)
+	var offenders []*dto.Metric
+	for _, m := range family.GetMetric() {
+		if m.GetGauge().GetValue() > max {
+			offenders = append(offenders, m)
+		}
+	}
+	if len(offenders) == 0 {
+		return
+	}
+	sort.SliceStable(offenders, func(i, j int) bool {
+		return offenders[i].GetGauge().GetValue() > offenders[j].GetGauge().GetValue()
+	})
+
+	lines := make([]string, 0, len(offenders))
+	for _, m := range offenders {
+		lines = append(lines, name+formatLabels(labelMap(m))+" "+s.formatValue(m.GetGauge().GetValue()))
+	}
+	s.errorf(name, "Expected every series of gauge %s to be at most %f but %d exceeded it:\n%s",
+		name, max, len(offenders), strings.Join(lines, "\n"))
+}
+
+// AssertCountTotal asserts the sum of every series of a counter family in the snapshot, whatever
+// their labels.
+func (s *Snapshot) AssertCountTotal(name string, expected float64) {
+	s.t.Helper()
+	if err := s.typeError(dto.MetricType_COUNTER, name); err != nil {
+		s.report(name, err)
+		return
+	}
+
+	// Counter not existing is the same as the counter having 0 value
+	if total := s.familySum(dto.MetricType_COUNTER, name); !s.floatEquals(total, expected) {
+		s.errorf(name, "Expected counter [%s] total across all series to be %f but was %f", name, expected, total)
+	}
+}
+
+// AssertGaugeSum asserts the sum of every series of a gauge family in the snapshot, whatever their
+// labels, e.g. the connections of every shard.
+func (s *Snapshot) AssertGaugeSum(name string, expected float64) {
+	s.t.Helper()
+	if err := s.typeError(dto.MetricType_GAUGE, name); err != nil {
+		s.report(name, err)
+		return
+	}
+
+	// Gauge not existing is the same as the gauge having 0 value
+	if sum := s.familySum(dto.MetricType_GAUGE, name); !s.floatEquals(sum, expected) {
+		s.errorf(name, "Expected gauge [%s] sum across all series to be %f but was %f", name, expected, sum)
+	}
+}
+
+// familySum returns the sum of the values of every series of a counter or gauge family.
+func (s *Snapshot) familySum(metricType dto.MetricType, name string) float64 {
+	family, _ := s.family(name)
+	var sum float64
+	for _, m := range family.GetMetric() {
+		value, _ := singleValue(metricType, m)
+		sum += value
+	}
+	return sum
+}
 
-	if actualValue := metric.GetCounter().GetValue(); !floatEquals(actualValue, value) {
-		s.t.Errorf("Expected counter value %f but was %f", value, actualValue)
+// AssertGaugeGreaterThan asserts that a gauge in the snapshot is greater than min.
+func (s *Snapshot) AssertGaugeGreaterThan(name string, labels map[string]string, min float64) {
+	s.t.Helper()
+	if actualValue, ok := s.gaugeValue(name, labels); ok && !(actualValue > min) {
+		s.errorf(name, "Expected gauge [%s] to be > %f but was %f", name, min, actualValue)
 	}
 }
 
-// AssertGauge asserts existence and value of a gauge in the snapshot.
-func (s *Snapshot) AssertGauge(name string, labels map[string]string, value float64) {
+// AssertGaugeLessThan asserts that a gauge in the snapshot is less than max.
+func (s *Snapshot) AssertGaugeLessThan(name string, labels map[string]string, max float64) {
 	s.t.Helper()
-	metric := s.GetMetric(dto.MetricType_GAUGE, name, labels)
+	if actualValue, ok := s.gaugeValue(name, labels); ok && !(actualValue < max) {
+		s.errorf(name, "Expected gauge [%s] to be < %f but was %f", name, max, actualValue)
+	}
+}
 
-	if metric == nil {
-		if value == 0 {
-			// Gauge not existing is the same as the counter having 0 value
+// AssertGaugeInRange asserts that a gauge in the snapshot is within [min, max].
+func (s *Snapshot) AssertGaugeInRange(name string, labels map[string]string, min, max float64) {
+	s.t.Helper()
+	if min > max {
+		s.errorf(name, "Invalid range [%f, %f] for gauge %s: min is greater than max", min, max, name)
+		return
+	}
+	if actualValue, ok := s.gaugeValue(name, labels); ok && !(actualValue >= min && actualValue <= max) {
+		s.errorf(name, "Expected gauge [%s] to be in [%f, %f] but was %f", name, min, max, actualValue)
+	}
+}
+
+// AssertCountAtLeast asserts that a counter in the snapshot is at least min.
+func (s *Snapshot) AssertCountAtLeast(name string, labels map[string]string, min float64) {
+	s.t.Helper()
+	metric, err := s.findMetric(dto.MetricType_COUNTER, name, labels)
+	if err != nil {
+		if isNotFound(err) && min <= 0 {
+			// Counter not existing is the same as the counter having 0 value
 			return
 		}
-		s.t.Errorf("Could not find Gauge %s with the labels %v", name, labels)
+		s.report(name, err)
+		return
 	}
 
-	if actualValue := metric.GetGauge().GetValue(); !floatEquals(actualValue, value) {
-		s.t.Errorf("Expected gauge value %f but was %f", value, actualValue)
+	if actualValue := metric.GetCounter().GetValue(); !(actualValue >= min) {
+		s.errorf(name, "Expected counter [%s] to be >= %f but was %f", name, min, actualValue)
 	}
 }
 
+// gaugeValue returns the value of a gauge, failing the test if there is no such gauge.
+func (s *Snapshot) gaugeValue(name string, labels map[string]string) (float64, bool) {
+	s.t.Helper()
+	metric, err := s.findMetric(dto.MetricType_GAUGE, name, labels)
+	if err != nil {
+		s.report(name, err)
+		return 0, false
+	}
+	return metric.GetGauge().GetValue(), true
+}
+
 // AssertSummary asserts that the existence and the sample sum and count of a summary in the snapshot.
-func (s *Snapshot) AssertSummary(name string, labels map[string]string, sum float64, count uint64) {
+// Comparisons, if any, replace the equality of the sample sum.
+func (s *Snapshot) AssertSummary(name string, labels map[string]string, sum float64, count uint64, cmp ...Comparison) {
 	s.t.Helper()
-	metric := s.GetMetric(dto.MetricType_SUMMARY, name, labels)
-	summary := metric.GetSummary()
+	s.report(name, s.CheckSummary(name, labels, sum, count, cmp...))
+}
 
-	if metric == nil {
-		if count == 0 {
+// CheckSummary is like AssertSummary but returns the failure as an error instead of failing the
+// test.
+func (s *Snapshot) CheckSummary(name string, labels map[string]string, sum float64, count uint64,
+	cmp ...Comparison) error {
+	metric, err := s.findMetric(dto.MetricType_SUMMARY, name, labels)
+	if err != nil {
+		if count == 0 && isNotFound(err) {
 			// Summary not existing is the same as the summary having 0 value
-			return
+			return nil
 		}
-		s.t.Errorf("Could not find Summary %s with the labels %v", name, labels)
+		return err
 	}
 
-	if actualSum := summary.GetSampleSum(); !floatEquals(actualSum, sum) {
-		s.t.Errorf("Expected summary [%s] sample sum to be %f but was %f", name, sum, actualSum)
+	summary := metric.GetSummary()
+	err = s.compareValue(dto.MetricType_SUMMARY, name, labels, "sample sum", sum, summary.GetSampleSum(), cmp)
+	if err != nil {
+		return err
 	}
 	if actualCount := summary.GetSampleCount(); actualCount != count {
-		s.t.Errorf("Expected summary [%s] sample count to be %d but was %d", name, count, actualCount)
+		return &ValueMismatchError{dto.MetricType_SUMMARY, name, labels, "sample count",
+			float64(count), float64(actualCount)}
 	}
+	return nil
 }
 
 // AssertHistogram asserts that the existence and the sample sum and count of a histogram in the
-// snapshot.
-func (s *Snapshot) AssertHistogram(name string, labels map[string]string, sum float64, count uint64) {
+// snapshot. Comparisons, if any, replace the equality of the sample sum.
+func (s *Snapshot) AssertHistogram(name string, labels map[string]string, sum float64, count uint64, cmp ...Comparison) {
 	s.t.Helper()
-	metric := s.GetMetric(dto.MetricType_HISTOGRAM, name, labels)
+	s.report(name, s.CheckHistogram(name, labels, sum, count, cmp...))
+}
+
+// CheckHistogram is like AssertHistogram but returns the failure as an error instead of failing the
+// test.
+func (s *Snapshot) CheckHistogram(name string, labels map[string]string, sum float64, count uint64,
+	cmp ...Comparison) error {
+	metric, err := s.findMetric(dto.MetricType_HISTOGRAM, name, labels)
+	if err != nil {
+		if count == 0 && isNotFound(err) {
+			// Histogram not existing is the same as the histogram having 0 value
+			return nil
+		}
+		return err
+	}
+
 	histogram := metric.GetHistogram()
+	err = s.compareValue(dto.MetricType_HISTOGRAM, name, labels, "sample sum", sum, histogram.GetSampleSum(), cmp)
+	if err != nil {
+		return err
+	}
+	if actualCount := histogram.GetSampleCount(); actualCount != count {
+		return &ValueMismatchError{dto.MetricType_HISTOGRAM, name, labels, "sample count",
+			float64(count), float64(actualCount)}
+	}
+	return nil
+}
+
+// AssertSummarySumInRange asserts that the sample sum of a summary in the snapshot is within
+// [min, max]. A NaN sample sum is never in range.
+func (s *Snapshot) AssertSummarySumInRange(name string, labels map[string]string, min, max float64) {
+	s.t.Helper()
+	metric, err := s.findMetric(dto.MetricType_SUMMARY, name, labels)
+	if err != nil {
+		if isNotFound(err) && min <= 0 && 0 <= max {
+			// Summary not existing is the same as the summary having 0 value
+			return
+		}
+		s.report(name, err)
+		return
+	}
+
+	if actualSum := metric.GetSummary().GetSampleSum(); math.IsNaN(actualSum) || actualSum < min || actualSum > max {
+		s.errorf(name, "Expected summary [%s] sample sum to be in [%f, %f] but was %f", name, min, max, actualSum)
+	}
+}
 
-	if metric == nil {
-		if count == 0 {
+// AssertHistogramSumInRange asserts that the sample sum of a histogram in the snapshot is within
+// [min, max]. A NaN sample sum is never in range.
+func (s *Snapshot) AssertHistogramSumInRange(name string, labels map[string]string, min, max float64) {
+	s.t.Helper()
+	metric, err := s.findMetric(dto.MetricType_HISTOGRAM, name, labels)
+	if err != nil {
+		if isNotFound(err) && min <= 0 && 0 <= max {
 			// Histogram not existing is the same as the histogram having 0 value
 			return
 		}
-		s.t.Errorf("Could not find Histogram %s with the labels %v", name, labels)
+		s.report(name, err)
+		return
 	}
 
-	if actualSum := histogram.GetSampleSum(); !floatEquals(actualSum, sum) {
-		s.t.Errorf("Expected histogram [%s] sample sum to be %f but was %f", name, sum, actualSum)
+	if actualSum := metric.GetHistogram().GetSampleSum(); math.IsNaN(actualSum) || actualSum < min || actualSum > max {
+		s.errorf(name, "Expected histogram [%s] sample sum to be in [%f, %f] but was %f", name, min, max, actualSum)
 	}
-	if actualCount := histogram.GetSampleCount(); actualCount != count {
-		s.t.Errorf("Expected histogram [%s] sample count to be %d but was %d", name, count, actualCount)
+}
+
+// AssertHistogramSumIsFinite asserts that the sample sum of a histogram in the snapshot is neither
+// NaN nor infinite.
+func (s *Snapshot) AssertHistogramSumIsFinite(name string, labels map[string]string) {
+	s.t.Helper()
+	metric, err := s.findMetric(dto.MetricType_HISTOGRAM, name, labels)
+	if err != nil {
+		if !isNotFound(err) {
+			s.report(name, err)
+		}
+		// Histogram not existing is the same as the histogram having 0 value
+		return
+	}
+
+	switch actualSum := metric.GetHistogram().GetSampleSum(); {
+	case math.IsNaN(actualSum):
+		s.errorf(name, "Histogram %s sample sum is NaN, did you observe a NaN value?", name)
+	case math.IsInf(actualSum, 0):
+		s.errorf(name, "Histogram %s sample sum is %f, did you observe an infinite value?", name, actualSum)
+	}
+}
+
+// AssertSummaryCountApprox asserts that the sample count of a summary in the snapshot is within
+// tolerance of count.
+func (s *Snapshot) AssertSummaryCountApprox(name string, labels map[string]string, count uint64, tolerance uint64) {
+	s.t.Helper()
+	s.assertCountApprox(dto.MetricType_SUMMARY, name, labels, count, tolerance)
+}
+
+// AssertHistogramCountApprox asserts that the sample count of a histogram in the snapshot is within
+// tolerance of count.
+func (s *Snapshot) AssertHistogramCountApprox(name string, labels map[string]string, count uint64, tolerance uint64) {
+	s.t.Helper()
+	s.assertCountApprox(dto.MetricType_HISTOGRAM, name, labels, count, tolerance)
+}
+
+func (s *Snapshot) assertCountApprox(metricType dto.MetricType, name string, labels map[string]string,
+	count uint64, tolerance uint64) {
+	s.t.Helper()
+	metric, err := s.findMetric(metricType, name, labels)
+	if err != nil {
+		if count <= tolerance && isNotFound(err) {
+			// Metric not existing is the same as the metric having 0 value
+			return
+		}
+		s.report(name, err)
+		return
+	}
+
+	actualCount := metric.GetSummary().GetSampleCount()
+	if metricType == dto.MetricType_HISTOGRAM {
+		actualCount = metric.GetHistogram().GetSampleCount()
+	}
+	if actualCount+tolerance < count || actualCount > count+tolerance {
+		s.errorf(name, "Expected %s [%s] sample count to be %d±%d but was %d",
+			strings.ToLower(typeTitle(metricType)), name, count, tolerance, actualCount)
 	}
 }
 
 // AssertSummaryNonZero asserts that the summary exists and its value is non-zero
 func (s *Snapshot) AssertSummaryNonZero(name string, labels map[string]string) {
 	s.t.Helper()
-	metric := s.GetMetric(dto.MetricType_SUMMARY, name, labels)
-	summary := metric.GetSummary()
+	metric, err := s.findMetric(dto.MetricType_SUMMARY, name, labels)
+	if err != nil {
+		s.report(name, err)
+		return
+	}
+
+	if actualSum := metric.GetSummary().GetSampleSum(); actualSum == 0 {
+		s.errorf(name, "Expected summary sample sum to be >0")
+	}
+}
+
+// AssertCounterNonZero asserts that the counter exists and its value is greater than zero
+func (s *Snapshot) AssertCounterNonZero(name string, labels map[string]string) {
+	s.t.Helper()
+	metric, err := s.findMetric(dto.MetricType_COUNTER, name, labels)
+	if err != nil {
+		s.report(name, err)
+		return
+	}
+
+	if actualValue := metric.GetCounter().GetValue(); actualValue <= 0 {
+		s.errorf(name, "Expected counter [%s] value to be >0 but was %f", name, actualValue)
+	}
+}
+
+// AssertGaugeNonZero asserts that the gauge exists and its value is non-zero
+func (s *Snapshot) AssertGaugeNonZero(name string, labels map[string]string) {
+	s.t.Helper()
+	metric, err := s.findMetric(dto.MetricType_GAUGE, name, labels)
+	if err != nil {
+		s.report(name, err)
+		return
+	}
+
+	if actualValue := metric.GetGauge().GetValue(); actualValue == 0 {
+		s.errorf(name, "Expected gauge [%s] value to be non-zero", name)
+	}
+}
 
-	if metric == nil {
-		s.t.Errorf("Could not find Summary %s with the labels %v", name, labels)
+// AssertHistogramNonZero asserts that the histogram exists and its value is non-zero
+func (s *Snapshot) AssertHistogramNonZero(name string, labels map[string]string) {
+	s.t.Helper()
+	metric, err := s.findMetric(dto.MetricType_HISTOGRAM, name, labels)
+	if err != nil {
+		s.report(name, err)
+		return
 	}
 
-	if actualSum := summary.GetSampleSum(); actualSum == 0 {
-		s.t.Errorf("Expected summary sample sum to be >0")
+	if actualSum := metric.GetHistogram().GetSampleSum(); actualSum == 0 {
+		s.errorf(name, "Expected histogram [%s] sample sum to be >0", name)
+	}
+}
+
+// AssertSampleSumAtLeast asserts that the sample sum of a summary or histogram in the snapshot is
+// at least min.
+func (s *Snapshot) AssertSampleSumAtLeast(metricType dto.MetricType, name string, labels map[string]string, min float64) {
+	s.t.Helper()
+	if metricType != dto.MetricType_SUMMARY && metricType != dto.MetricType_HISTOGRAM {
+		s.errorf(name, "Sample sums are only recorded by summaries and histograms, not %s",
+			dto.MetricType_name[int32(metricType)])
+		return
+	}
+	metric, err := s.findMetric(metricType, name, labels)
+	if err != nil {
+		if isNotFound(err) && min <= 0 {
+			// Metric not existing is the same as the metric having 0 value
+			return
+		}
+		s.report(name, err)
+		return
+	}
+
+	actualSum := metric.GetSummary().GetSampleSum()
+	if metricType == dto.MetricType_HISTOGRAM {
+		actualSum = metric.GetHistogram().GetSampleSum()
+	}
+	if actualSum < min {
+		s.errorf(name, "Expected %s [%s] sample sum to be at least %f but was %f",
+			strings.ToLower(typeTitle(metricType)), name, min, actualSum)
 	}
 }
 
 // AssertHistogramSampleCount asserts that the histogram exists and contains exact number of samples
 func (s *Snapshot) AssertHistogramSampleCount(name string, sampleCount uint64) {
 	s.t.Helper()
-	metric := s.GetMetric(dto.MetricType_HISTOGRAM, name, map[string]string{})
-	histogram := metric.GetHistogram()
-
-	if histogram == nil {
-		s.t.Errorf("Could not find Histogram %s", name)
+	metric, err := s.findMetric(dto.MetricType_HISTOGRAM, name, map[string]string{})
+	if err != nil {
+		s.report(name, err)
+		return
 	}
 
-	if sampleCount != histogram.GetSampleCount() {
-		s.t.Errorf("Expected histogram sample count did not match: %d != %d",
+	if histogram := metric.GetHistogram(); sampleCount != histogram.GetSampleCount() {
+		s.errorf(name, "Expected histogram sample count did not match: %d != %d",
 			sampleCount, histogram.GetSampleCount())
 	}
 }
 
 // GetMetric returns a matching metric from the snapshot
 func (s *Snapshot) GetMetric(metricType dto.MetricType, name string, labels map[string]string) *dto.Metric {
-	family, ok := s.MetricMap[name]
+	s.t.Helper()
+	metric, err := s.unrecorded().findMetric(metricType, name, labels)
+	var typeErr *TypeMismatchError
+	if errors.As(err, &typeErr) {
+		s.errorf(name, "%s", err)
+	}
+	return metric
+}
+
+// findMetric returns the series of the named family whose labels are exactly the given labels.
+func (s *Snapshot) findMetric(metricType dto.MetricType, name string, labels map[string]string) (*dto.Metric, error) {
+	family, ok := s.family(name)
 	if !ok {
-		return nil
+		return nil, &MetricNotFoundError{metricType, name, labels, nil, s.matchers}
 	}
 
-	if actualType := family.GetType(); actualType != metricType {
-		s.t.Errorf("Expected %s to be of type %s but was %s",
-			name, dto.MetricType_name[int32(metricType)], dto.MetricType_name[int32(actualType)])
-		return nil
+	if err := s.typeError(metricType, name); err != nil {
+		return nil, err
+	}
+	if len(s.matchers) > 0 {
+		return s.findMetricMatchers(family, metricType, name, labels)
 	}
 
-	var metric *dto.Metric
 Outer:
 	for _, m := range family.GetMetric() {
 		labelPairs := m.GetLabel()
@@ -178,14 +846,336 @@ Outer:
 				continue Outer
 			}
 		}
-		metric = m
-		break
+		return m, nil
 	}
 
-	return metric
+	return nil, &MetricNotFoundError{metricType, name, labels, closestSeries(family, labels), nil}
+}
+
+// maxClosestSeries is the number of near misses reported when a series cannot be found.
+const maxClosestSeries = 3
+
+// closestSeries returns the label sets of the series of a family differing in the fewest labels
+// from labels, e.g. by the case of a value, sorted by labels.
+func closestSeries(family *dto.MetricFamily, labels map[string]string) []map[string]string {
+	var candidates []map[string]string
+	for _, m := range family.GetMetric() {
+		candidates = append(candidates, labelMap(m))
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return formatLabels(candidates[i]) < formatLabels(candidates[j])
+	})
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return labelDistance(candidates[i], labels) < labelDistance(candidates[j], labels)
+	})
+
+	var closest []map[string]string
+	for _, candidate := range candidates {
+		if len(closest) == maxClosestSeries || labelDistance(candidate, labels) > labelDistance(candidates[0], labels) {
+			break
+		}
+		closest = append(closest, candidate)
+	}
+	return closest
+}
+
+// labelDistance returns the number of labels missing from, added to or changed in actual.
+func labelDistance(actual, expected map[string]string) int {
+	distance := 0
+	for name, value := range expected {
+		if actualValue, ok := actual[name]; !ok || actualValue != value {
+			distance++
+		}
+	}
+	for name := range actual {
+		if _, ok := expected[name]; !ok {
+			distance++
+		}
+	}
+	return distance
+}
+
+// family returns the metric family called name for an assertion, after applying the name prefix of
+// the snapshot, and records that it was asserted on.
+func (s *Snapshot) family(name string) (*dto.MetricFamily, bool) {
+	s.asserted.add(s.prefix + name)
+	return s.lookup(name)
 }
 
-func floatEquals(a, b float64) bool {
-	epsilon := 0.00000001
-	return math.Abs(a-b) < epsilon
-}
\ No newline at end of file
+// lookup returns the metric family called name, after applying the name prefix of the snapshot,
+// without recording an assertion on it.
+func (s *Snapshot) lookup(name string) (*dto.MetricFamily, bool) {
+	family, ok := s.MetricMap[s.prefix+name]
+	return family, ok
+}
+
+// markAsserted records that the metric families with the given full names were asserted on, e.g.
+// by an assertion over the whole snapshot.
+func (s *Snapshot) markAsserted(names ...string) {
+	for _, name := range names {
+		s.asserted.add(name)
+	}
+}
+
+// unrecorded returns a copy of the snapshot whose lookups are not recorded as assertions, for
+// getters built on the lookups of assertions.
+func (s *Snapshot) unrecorded() *Snapshot {
+	c := *s
+	c.asserted = newAssertedFamilies()
+	return &c
+}
+
+// hasSeries reports whether the named family, of any type, has a series with exactly the given
+// labels.
+func (s *Snapshot) hasSeries(name string, labels map[string]string) bool {
+	family, ok := s.family(name)
+	return ok && containsSeries([]*dto.MetricFamily{family}, labels)
+}
+
+// typeError returns a TypeMismatchError if the named family exists but is not of the given type.
+func (s *Snapshot) typeError(metricType dto.MetricType, name string) error {
+	if family, ok := s.family(name); ok && family.GetType() != metricType {
+		return &TypeMismatchError{name, metricType, family.GetType()}
+	}
+	return nil
+}
+
+// report fails the test with err, if any, on behalf of the metric family called name.
+func (s *Snapshot) report(name string, err error) {
+	s.t.Helper()
+	if err != nil {
+		s.errorf(name, "%s", s.renderError(err))
+	}
+}
+
+// renderError renders err for a failure message, with the values of a ValueMismatchError or
+// ComparisonError in the float format of the snapshot.
+func (s *Snapshot) renderError(err error) string {
+	var mismatch *ValueMismatchError
+	if errors.As(err, &mismatch) {
+		return mismatch.message(s.formatValue)
+	}
+	var comparison *ComparisonError
+	if errors.As(err, &comparison) {
+		return comparison.message(s.formatValue)
+	}
+	return err.Error()
+}
+
+// errorf reports an assertion failure on the metric family called name, or on the whole snapshot if
+// name is empty.
+func (s *Snapshot) errorf(name string, format string, args ...interface{}) {
+	s.t.Helper()
+	formatted := make([]interface{}, len(args))
+	for i, arg := range args {
+		if f, ok := arg.(float64); ok {
+			arg = floatArg{f, s.formatValue}
+		}
+		formatted[i] = arg
+	}
+	msg := fmt.Sprintf(format, formatted...)
+	if s.context != "" {
+		msg = "[" + s.context + "] " + msg
+	}
+	if s.verbose && name != "" {
+		msg += "\n" + s.describeFamily(name)
+	}
+	if s.fatal {
+		s.t.Fatal(msg)
+		return
+	}
+	s.t.Error(msg)
+}
+
+// formatValue renders a value for a failure message.
+func (s *Snapshot) formatValue(f float64) string {
+	if s.floatFormat != nil {
+		return s.floatFormat(f)
+	}
+	return formatFloat(f)
+}
+
+// floatArg is a float argument of a failure message, rendered by the float format of the snapshot
+// whatever its formatting verb.
+type floatArg struct {
+	value  float64
+	format func(float64) string
+}
+
+func (a floatArg) Format(f fmt.State, verb rune) {
+	io.WriteString(f, a.format(a.value))
+}
+
+// describeFamily renders every series of the named family in the text exposition format.
+func (s *Snapshot) describeFamily(name string) string {
+	family, ok := s.lookup(name)
+	if !ok {
+		// Assertions over the whole snapshot report families by their full name
+		family, ok = s.MetricMap[name]
+	}
+	if !ok {
+		return fmt.Sprintf("no metric family %s in the snapshot, its metric families are %v",
+			s.prefix+name, sortedNames(s.MetricMap))
+	}
+	return strings.TrimSuffix(renderFamily(family, s.floatFormat), "\n")
+}
+
+// String renders every series of the snapshot in the text exposition format, sorted by metric
+// name and labels, with the float format of the snapshot if any.
+func (s *Snapshot) String() string {
+	var buf strings.Builder
+	for _, name := range sortedNames(s.MetricMap) {
+		buf.WriteString(renderFamily(s.MetricMap[name], s.floatFormat))
+	}
+	return buf.String()
+}
+
+// Dump writes every series of the snapshot to w, as rendered by String.
+func (s *Snapshot) Dump(w io.Writer) error {
+	_, err := io.WriteString(w, s.String())
+	return err
+}
+
+// renderFamily renders every series of a family in the text exposition format, sorted by labels.
+// Sample values are rendered with format, if not nil.
+func renderFamily(family *dto.MetricFamily, format func(float64) string) string {
+	sorted := &dto.MetricFamily{
+		Name:   family.Name,
+		Help:   family.Help,
+		Type:   family.Type,
+		Unit:   family.Unit,
+		Metric: append([]*dto.Metric(nil), family.GetMetric()...),
+	}
+	sort.SliceStable(sorted.Metric, func(i, j int) bool {
+		return formatLabels(labelMap(sorted.Metric[i])) < formatLabels(labelMap(sorted.Metric[j]))
+	})
+	var buf strings.Builder
+	if _, err := expfmt.MetricFamilyToText(&buf, sorted); err != nil {
+		return fmt.Sprintf("could not render metric family %s: %v\n", family.GetName(), err)
+	}
+	if format == nil {
+		return buf.String()
+	}
+	lines := strings.SplitAfter(buf.String(), "\n")
+	for i, line := range lines {
+		lines[i] = formatSampleValue(line, format)
+	}
+	return strings.Join(lines, "")
+}
+
+// formatSampleValue renders the value of a sample line of the text exposition format with format,
+// leaving comment lines, labels and timestamps as they are.
+func formatSampleValue(line string, format func(float64) string) string {
+	end := sampleLabelsEnd(line)
+	if strings.HasPrefix(line, "#") || end < 0 {
+		return line
+	}
+	fields := strings.Fields(line[end:])
+	if len(fields) == 0 {
+		return line
+	}
+	value, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return line
+	}
+	fields[0] = format(value)
+	formatted := line[:end] + " " + strings.Join(fields, " ")
+	if strings.HasSuffix(line, "\n") {
+		formatted += "\n"
+	}
+	return formatted
+}
+
+// sampleLabelsEnd returns the index right after the name and labels of a sample line, or -1 if
+// there is none. Label values may hold braces and escaped quotes.
+func sampleLabelsEnd(line string) int {
+	end := strings.IndexAny(line, "{ ")
+	if end < 0 || line[end] == ' ' {
+		return end
+	}
+	quoted := false
+	for i := end + 1; i < len(line); i++ {
+		switch c := line[i]; {
+		case quoted && c == '\\':
+			i++
+		case c == '"':
+			quoted = !quoted
+		case !quoted && c == '}':
+			return i + 1
+		}
+	}
+	return -1
+}
+
+// defaultTolerance is the tolerance within which values are considered equal unless configured
+// otherwise. It is also used to match bucket upper bounds and quantiles.
+const defaultTolerance = 0.00000001
+
+// floatEquals reports whether two values are equal within the tolerance of the snapshot.
+func (s *Snapshot) floatEquals(a, b float64) bool {
+	return floatEquals(a, b, s.tolerance)
+}
+
+func floatEquals(a, b, epsilon float64) bool {
+	return a == b || math.Abs(a-b) < epsilon || math.IsNaN(a) && math.IsNaN(b)
+}
+
+// labelMap returns the label pairs of a series as a map
+func labelMap(m *dto.Metric) map[string]string {
+	return labelPairsMap(m.GetLabel())
+}
+
+// labelPairsMap returns label pairs, e.g. of an exemplar, as a map
+func labelPairsMap(labelPairs []*dto.LabelPair) map[string]string {
+	labels := make(map[string]string, len(labelPairs))
+	for _, labelPair := range labelPairs {
+		labels[labelPair.GetName()] = labelPair.GetValue()
+	}
+	return labels
+}
+
+// formatLabels renders labels in the text exposition format, e.g. {code="200",method="GET"}
+func formatLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		pairs = append(pairs, fmt.Sprintf("%s=%q", name, labels[name]))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func labelsEqual(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name, value := range a {
+		if other, ok := b[name]; !ok || other != value {
+			return false
+		}
+	}
+	return true
+}
+
+// sortedNames returns the names of the metric families in metricMap in sorted order
+func sortedNames(metricMap map[string]*dto.MetricFamily) []string {
+	names := make([]string, 0, len(metricMap))
+	for name := range metricMap {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// isRuntimeMetric reports whether name belongs to the Go or process collectors, whose values are
+// outside the control of a test.
+func isRuntimeMetric(name string) bool {
+	return strings.HasPrefix(name, "go_") || strings.HasPrefix(name, "process_")
+}
@@ -0,0 +1,336 @@
+package promtest
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// fakeTB records the failures of the assertions under test instead of failing the test.
+type fakeTB struct {
+	failures []string
+}
+
+func (t *fakeTB) Helper() {}
+
+func (t *fakeTB) Error(args ...interface{}) {
+	t.failures = append(t.failures, fmt.Sprint(args...))
+}
+
+func (t *fakeTB) Errorf(format string, args ...interface{}) {
+	t.failures = append(t.failures, fmt.Sprintf(format, args...))
+}
+
+func (t *fakeTB) Fatal(args ...interface{}) {
+	t.Error(args...)
+}
+
+func (t *fakeTB) Fatalf(format string, args ...interface{}) {
+	t.Errorf(format, args...)
+}
+
+// assertSingleFailure fails the test unless exactly one failure containing text was recorded.
+func assertSingleFailure(t *testing.T, ft *fakeTB, text string) {
+	t.Helper()
+	if len(ft.failures) != 1 {
+		t.Fatalf("Expected exactly one failure but got %d: %q", len(ft.failures), ft.failures)
+	}
+	if !strings.Contains(ft.failures[0], text) {
+		t.Errorf("Expected the failure to contain %q but was %q", text, ft.failures[0])
+	}
+}
+
+func TestAssertCrossTypeMisuse(t *testing.T) {
+	labels := map[string]string{"code": "200"}
+	tests := []struct {
+		name    string
+		metric  func() prometheus.Collector
+		assert  func(s *Snapshot)
+		failure string
+	}{
+		{
+			name:    "AssertCount on a gauge",
+			metric:  newFooGauge,
+			assert:  func(s *Snapshot) { s.AssertCount("foo", labels, 1) },
+			failure: "foo is a GAUGE, not a COUNTER",
+		},
+		{
+			name:    "AssertCount of 0 on a gauge",
+			metric:  newFooGauge,
+			assert:  func(s *Snapshot) { s.AssertCount("foo", labels, 0) },
+			failure: "foo is a GAUGE, not a COUNTER",
+		},
+		{
+			name:    "AssertCount on a missing series of a gauge",
+			metric:  newFooGauge,
+			assert:  func(s *Snapshot) { s.AssertCount("foo", map[string]string{"code": "500"}, 0) },
+			failure: "foo is a GAUGE, not a COUNTER",
+		},
+		{
+			name:    "AssertCounterEqualsInt on a gauge",
+			metric:  newFooGauge,
+			assert:  func(s *Snapshot) { s.AssertCounterEqualsInt("foo", labels, 1) },
+			failure: "foo is a GAUGE, not a COUNTER",
+		},
+		{
+			name:    "AssertCountAtLeast on a gauge",
+			metric:  newFooGauge,
+			assert:  func(s *Snapshot) { s.AssertCountAtLeast("foo", labels, 1) },
+			failure: "foo is a GAUGE, not a COUNTER",
+		},
+		{
+			name:    "AssertCounterNonZero on a gauge",
+			metric:  newFooGauge,
+			assert:  func(s *Snapshot) { s.AssertCounterNonZero("foo", labels) },
+			failure: "foo is a GAUGE, not a COUNTER",
+		},
+		{
+			name:    "AssertGauge on a counter",
+			metric:  newFooCounter,
+			assert:  func(s *Snapshot) { s.AssertGauge("foo", labels, 1) },
+			failure: "foo is a COUNTER, not a GAUGE",
+		},
+		{
+			name:    "AssertGauge of 0 on a counter",
+			metric:  newFooCounter,
+			assert:  func(s *Snapshot) { s.AssertGauge("foo", labels, 0) },
+			failure: "foo is a COUNTER, not a GAUGE",
+		},
+		{
+			name:    "AssertGaugeInRange on a counter",
+			metric:  newFooCounter,
+			assert:  func(s *Snapshot) { s.AssertGaugeInRange("foo", labels, 0, 2) },
+			failure: "foo is a COUNTER, not a GAUGE",
+		},
+		{
+			name:    "AssertGaugeNonZero on a counter",
+			metric:  newFooCounter,
+			assert:  func(s *Snapshot) { s.AssertGaugeNonZero("foo", labels) },
+			failure: "foo is a COUNTER, not a GAUGE",
+		},
+		{
+			name:    "AssertHistogram on a summary",
+			metric:  newFooSummary,
+			assert:  func(s *Snapshot) { s.AssertHistogram("foo", labels, 1, 1) },
+			failure: "foo is a SUMMARY, not a HISTOGRAM",
+		},
+		{
+			name:    "AssertHistogram of 0 on a summary",
+			metric:  newFooSummary,
+			assert:  func(s *Snapshot) { s.AssertHistogram("foo", labels, 0, 0) },
+			failure: "foo is a SUMMARY, not a HISTOGRAM",
+		},
+		{
+			name:    "AssertHistogramBucket on a summary",
+			metric:  newFooSummary,
+			assert:  func(s *Snapshot) { s.AssertHistogramBucket("foo", labels, 1, 1) },
+			failure: "foo is a SUMMARY, not a HISTOGRAM",
+		},
+		{
+			name:    "AssertSummary on a histogram",
+			metric:  newFooHistogram,
+			assert:  func(s *Snapshot) { s.AssertSummary("foo", labels, 1, 1) },
+			failure: "foo is a HISTOGRAM, not a SUMMARY",
+		},
+		{
+			name:    "AssertSummary of 0 on a histogram",
+			metric:  newFooHistogram,
+			assert:  func(s *Snapshot) { s.AssertSummary("foo", labels, 0, 0) },
+			failure: "foo is a HISTOGRAM, not a SUMMARY",
+		},
+		{
+			name:    "AssertSummaryQuantile on a histogram",
+			metric:  newFooHistogram,
+			assert:  func(s *Snapshot) { s.AssertSummaryQuantile("foo", labels, 0.5, 1) },
+			failure: "foo is a HISTOGRAM, not a SUMMARY",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ft := &fakeTB{}
+			r := NewTestRegistry(ft)
+			r.MustRegister(tt.metric())
+			s, err := r.TakeSnapshot()
+			if err != nil {
+				t.Fatalf("Could not take a snapshot: %v", err)
+			}
+			tt.assert(s)
+			assertSingleFailure(t, ft, tt.failure)
+		})
+	}
+}
+
+func newFooCounter() prometheus.Collector {
+	c := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "foo", Help: "foo"}, []string{"code"})
+	c.WithLabelValues("200").Inc()
+	return c
+}
+
+func newFooGauge() prometheus.Collector {
+	g := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "foo", Help: "foo"}, []string{"code"})
+	g.WithLabelValues("200").Set(1)
+	return g
+}
+
+func newFooSummary() prometheus.Collector {
+	sm := prometheus.NewSummaryVec(prometheus.SummaryOpts{Name: "foo", Help: "foo",
+		Objectives: map[float64]float64{0.5: 0.05}}, []string{"code"})
+	sm.WithLabelValues("200").Observe(1)
+	return sm
+}
+
+func newFooHistogram() prometheus.Collector {
+	h := prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "foo", Help: "foo",
+		Buckets: []float64{1}}, []string{"code"})
+	h.WithLabelValues("200").Observe(1)
+	return h
+}
+
+func TestRequireReportsOnce(t *testing.T) {
+	ft := &fakeTB{}
+	r := NewTestRegistry(ft)
+	r.MustRegister(newFooCounter())
+	s, err := r.TakeSnapshot()
+	if err != nil {
+		t.Fatalf("Could not take a snapshot: %v", err)
+	}
+	s.Require().AssertCount("foo", map[string]string{"code": "200"}, 2)
+	assertSingleFailure(t, ft, "Expected counter [foo] value to be 2 but was 1")
+}
+
+func TestStrictWithoutCleanup(t *testing.T) {
+	for name, opt := range map[string]Option{"Strict": Strict(), "DumpOnFailure": DumpOnFailure()} {
+		t.Run(name, func(t *testing.T) {
+			ft := &fakeTB{}
+			NewTestRegistry(ft, opt)
+			assertSingleFailure(t, ft, "require a TB implementing Cleanup")
+		})
+	}
+}
+
+func TestMissingSeriesReportsClosestSeries(t *testing.T) {
+	labels := map[string]string{"code": "201"}
+	tests := []struct {
+		name   string
+		metric func() prometheus.Collector
+		assert func(s *Snapshot)
+	}{
+		{"AssertSummarySumInRange", newFooSummary, func(s *Snapshot) { s.AssertSummarySumInRange("foo", labels, 1, 2) }},
+		{"AssertHistogramSumInRange", newFooHistogram, func(s *Snapshot) { s.AssertHistogramSumInRange("foo", labels, 1, 2) }},
+		{"AssertSampleSumAtLeast", newFooHistogram, func(s *Snapshot) {
+			s.AssertSampleSumAtLeast(dto.MetricType_HISTOGRAM, "foo", labels, 1)
+		}},
+		{"AssertGaugeBoundedAcross", newFooGauge, func(s *Snapshot) { s.AssertGaugeBoundedAcross("foo", labels, 1, 2, s) }},
+		{"AssertHistogramSLO", newFooHistogram, func(s *Snapshot) { s.AssertHistogramSLO("foo", labels, 1, 0.5) }},
+		{"AssertCountMatching", newFooCounter, func(s *Snapshot) { s.AssertCountMatching("foo", labels, 1) }},
+		{"AssertGaugeMatching", newFooGauge, func(s *Snapshot) { s.AssertGaugeMatching("foo", labels, 1) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ft := &fakeTB{}
+			r := NewTestRegistry(ft)
+			r.MustRegister(tt.metric())
+			s, err := r.TakeSnapshot()
+			if err != nil {
+				t.Fatalf("Could not take a snapshot: %v", err)
+			}
+			tt.assert(s)
+			assertSingleFailure(t, ft, `the closest series are:
+foo{code="200"}: code is "200", not "201"`)
+		})
+	}
+}
+
+func TestWithFloatFormat(t *testing.T) {
+	ft := &fakeTB{}
+	r := NewTestRegistry(ft)
+	g := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "foo", Help: "foo"}, []string{"room"})
+	r.MustRegister(g)
+	g.WithLabelValues(`a} "b"`).Set(3.14159)
+	s, err := r.TakeSnapshot()
+	if err != nil {
+		t.Fatalf("Could not take a snapshot: %v", err)
+	}
+	s = s.WithFloatFormat(func(f float64) string { return strconv.FormatFloat(f, 'f', 2, 64) })
+
+	if expected := "foo{room=\"a} \\\"b\\\"\"} 3.14\n"; !strings.HasSuffix(s.String(), expected) {
+		t.Errorf("Expected the snapshot to end with %q but was %q", expected, s.String())
+	}
+	s.AssertExposition("# HELP foo foo\n# TYPE foo gauge\nfoo{room=\"a} \\\"b\\\"\"} 3.1416\n")
+	if len(ft.failures) != 0 {
+		t.Errorf("Expected the values rounded to two decimals to be equal but got %q", ft.failures)
+	}
+}
+
+func TestSumInRange(t *testing.T) {
+	nanSummary := func() prometheus.Collector {
+		sm := prometheus.NewSummaryVec(prometheus.SummaryOpts{Name: "foo", Help: "foo"}, []string{"code"})
+		sm.WithLabelValues("200").Observe(math.NaN())
+		return sm
+	}
+	nanHistogram := func() prometheus.Collector {
+		h := prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "foo", Help: "foo"}, []string{"code"})
+		h.WithLabelValues("200").Observe(math.NaN())
+		return h
+	}
+	labels, missing := map[string]string{"code": "200"}, map[string]string{"code": "500"}
+	tests := []struct {
+		name    string
+		metric  func() prometheus.Collector
+		assert  func(s *Snapshot)
+		failure string
+	}{
+		{
+			name:    "AssertSummarySumInRange of NaN",
+			metric:  nanSummary,
+			assert:  func(s *Snapshot) { s.AssertSummarySumInRange("foo", labels, 0, 1) },
+			failure: "Expected summary [foo] sample sum to be in [0, 1] but was NaN",
+		},
+		{
+			name:    "AssertHistogramSumInRange of NaN",
+			metric:  nanHistogram,
+			assert:  func(s *Snapshot) { s.AssertHistogramSumInRange("foo", labels, 0, 1) },
+			failure: "Expected histogram [foo] sample sum to be in [0, 1] but was NaN",
+		},
+		{
+			name:   "AssertSummarySumInRange of a missing series with 0 in range",
+			metric: newFooSummary,
+			assert: func(s *Snapshot) { s.AssertSummarySumInRange("foo", missing, 0, 1) },
+		},
+		{
+			name:   "AssertHistogramSumInRange of a missing series with 0 in range",
+			metric: newFooHistogram,
+			assert: func(s *Snapshot) { s.AssertHistogramSumInRange("foo", missing, -1, 1) },
+		},
+		{
+			name:    "AssertHistogramSumInRange of a missing series without 0 in range",
+			metric:  newFooHistogram,
+			assert:  func(s *Snapshot) { s.AssertHistogramSumInRange("foo", missing, 1, 2) },
+			failure: "Could not find Histogram foo",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ft := &fakeTB{}
+			r := NewTestRegistry(ft)
+			r.MustRegister(tt.metric())
+			s, err := r.TakeSnapshot()
+			if err != nil {
+				t.Fatalf("Could not take a snapshot: %v", err)
+			}
+			tt.assert(s)
+			if tt.failure == "" {
+				if len(ft.failures) != 0 {
+					t.Errorf("Expected no failure but got %q", ft.failures)
+				}
+				return
+			}
+			assertSingleFailure(t, ft, tt.failure)
+		})
+	}
+}
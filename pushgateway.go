@@ -0,0 +1,177 @@
+package promtest
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+// FakePushGateway is an in-memory Pushgateway serving the push API over httptest, e.g. for tests of
+// batch jobs pushing with the push package of client_golang:
+//
+//	gw := promtest.NewFakePushGateway(t)
+//	push.New(gw.URL(), "batch").Collector(processed).Push()
+//	gw.Snapshot().AssertCount("processed_total", map[string]string{"job": "batch"}, 3)
+//
+// PUT replaces a grouping, POST replaces the pushed metric families of a grouping and DELETE
+// deletes a grouping. Unlike a real Pushgateway, it does not add push_time_seconds and
+// push_failure_time_seconds.
+type FakePushGateway struct {
+	t      TB
+	server *httptest.Server
+
+	mu     sync.Mutex
+	groups map[string]map[string]*dto.MetricFamily
+}
+
+// NewFakePushGateway starts a FakePushGateway. It is closed when the test ends if t implements
+// Cleanup like testing.TB, otherwise Close must be called.
+func NewFakePushGateway(t TB) *FakePushGateway {
+	p := &FakePushGateway{t: t, groups: make(map[string]map[string]*dto.MetricFamily)}
+	p.server = httptest.NewServer(http.HandlerFunc(p.serveHTTP))
+	if ct, ok := t.(cleanupTB); ok {
+		ct.Cleanup(p.Close)
+	}
+	return p
+}
+
+// URL returns the URL to push to.
+func (p *FakePushGateway) URL() string {
+	return p.server.URL
+}
+
+// Close shuts the FakePushGateway down.
+func (p *FakePushGateway) Close() {
+	p.server.Close()
+}
+
+// Snapshot creates a snapshot of the metrics pushed to every grouping, with the labels of their
+// grouping key, e.g. job.
+func (p *FakePushGateway) Snapshot() *Snapshot {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var families []*dto.MetricFamily
+	for _, group := range p.groups {
+		for _, family := range group {
+			families = append(families, family)
+		}
+	}
+	return NewSnapshotFromFamilies(p.t, families)
+}
+
+// serveHTTP handles the push API, /metrics/job/<job>{/<label>/<value>}.
+func (p *FakePushGateway) serveHTTP(w http.ResponseWriter, req *http.Request) {
+	grouping, err := parseGroupingKey(req.URL.Path)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	key := formatLabels(grouping)
+
+	if req.Method == http.MethodDelete {
+		p.mu.Lock()
+		delete(p.groups, key)
+		p.mu.Unlock()
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	if req.Method != http.MethodPut && req.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	format := expfmt.ResponseFormat(req.Header)
+	if format == expfmt.NewFormat(expfmt.TypeUnknown) {
+		format = expfmt.NewFormat(expfmt.TypeTextPlain)
+	}
+	families, err := decodeFamilies(req.Body, format)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	pushed := make(map[string]*dto.MetricFamily, len(families))
+	for _, family := range families {
+		if err := addGroupingLabels(family, grouping); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		pushed[family.GetName()] = family
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	group, ok := p.groups[key]
+	if !ok || req.Method == http.MethodPut {
+		group = make(map[string]*dto.MetricFamily, len(pushed))
+		p.groups[key] = group
+	}
+	for name, family := range pushed {
+		group[name] = family
+	}
+}
+
+// parseGroupingKey parses the grouping key of a push path, decoding label values with the @base64
+// suffix.
+func parseGroupingKey(path string) (map[string]string, error) {
+	parts := strings.Split(strings.TrimPrefix(path, "/metrics/"), "/")
+	if !strings.HasPrefix(path, "/metrics/") || len(parts)%2 != 0 || parts[len(parts)-1] == "" {
+		return nil, fmt.Errorf("invalid push path %s, expected /metrics/job/<job>{/<label>/<value>}", path)
+	}
+	grouping := make(map[string]string, len(parts)/2)
+	for i := 0; i < len(parts); i += 2 {
+		name, value := parts[i], parts[i+1]
+		if strings.HasSuffix(name, "@base64") {
+			name = strings.TrimSuffix(name, "@base64")
+			decoded, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+			if err != nil {
+				return nil, fmt.Errorf("invalid base64 value of label %s: %v", name, err)
+			}
+			value = string(decoded)
+		}
+		grouping[name] = value
+	}
+	if grouping["job"] == "" {
+		return nil, fmt.Errorf("invalid push path %s, the job label is missing", path)
+	}
+	return grouping, nil
+}
+
+// addGroupingLabels adds the labels of a grouping key to every series of a pushed family. Like a
+// real Pushgateway, it rejects series whose labels conflict with the grouping key.
+func addGroupingLabels(family *dto.MetricFamily, grouping map[string]string) error {
+	for _, m := range family.GetMetric() {
+		labels := labelMap(m)
+		for name, value := range grouping {
+			if actual, ok := labels[name]; ok && actual != value {
+				return fmt.Errorf("pushed series %s%s conflicts with grouping label %s=%q",
+					family.GetName(), formatLabels(labels), name, value)
+			}
+			labels[name] = value
+		}
+		m.Label = labelPairs(labels)
+	}
+	return nil
+}
+
+// labelPairs returns labels as label pairs sorted by name
+func labelPairs(labels map[string]string) []*dto.LabelPair {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := make([]*dto.LabelPair, 0, len(names))
+	for _, name := range names {
+		name, value := name, labels[name]
+		pairs = append(pairs, &dto.LabelPair{Name: &name, Value: &value})
+	}
+	return pairs
+}
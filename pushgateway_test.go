@@ -0,0 +1,112 @@
+package promtest
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+)
+
+func newPushedCounter(name string, value float64) prometheus.Counter {
+	c := prometheus.NewCounter(prometheus.CounterOpts{Name: name, Help: name})
+	c.Add(value)
+	return c
+}
+
+func TestFakePushGateway(t *testing.T) {
+	ft := &fakeTB{}
+	gw := NewFakePushGateway(ft)
+	defer gw.Close()
+	batch := map[string]string{"job": "batch", "instance": "a"}
+
+	pusher := push.New(gw.URL(), "batch").Grouping("instance", "a")
+	if err := pusher.Collector(newPushedCounter("processed_total", 3)).Collector(newPushedCounter("failed_total", 1)).Push(); err != nil {
+		t.Fatalf("Could not push: %v", err)
+	}
+	s := gw.Snapshot()
+	s.AssertCount("processed_total", batch, 3)
+	s.AssertCount("failed_total", batch, 1)
+
+	// POST only replaces the pushed families of the grouping
+	if err := push.New(gw.URL(), "batch").Grouping("instance", "a").Collector(newPushedCounter("processed_total", 5)).Add(); err != nil {
+		t.Fatalf("Could not add: %v", err)
+	}
+	s = gw.Snapshot()
+	s.AssertCount("processed_total", batch, 5)
+	s.AssertCount("failed_total", batch, 1)
+
+	// PUT replaces the whole grouping
+	if err := push.New(gw.URL(), "batch").Grouping("instance", "a").Collector(newPushedCounter("processed_total", 7)).Push(); err != nil {
+		t.Fatalf("Could not push: %v", err)
+	}
+	s = gw.Snapshot()
+	s.AssertCount("processed_total", batch, 7)
+	s.AssertMetricAbsent("failed_total")
+
+	// Another grouping is not replaced
+	if err := push.New(gw.URL(), "batch").Grouping("instance", "b").Collector(newPushedCounter("processed_total", 2)).Push(); err != nil {
+		t.Fatalf("Could not push: %v", err)
+	}
+	if err := push.New(gw.URL(), "batch").Grouping("instance", "a").Delete(); err != nil {
+		t.Fatalf("Could not delete: %v", err)
+	}
+	s = gw.Snapshot()
+	s.AssertSeriesCount("processed_total", 1)
+	s.AssertCount("processed_total", map[string]string{"job": "batch", "instance": "b"}, 2)
+	if len(ft.failures) != 0 {
+		t.Errorf("Expected no failure but got %q", ft.failures)
+	}
+}
+
+func TestFakePushGatewayBase64GroupingValues(t *testing.T) {
+	ft := &fakeTB{}
+	gw := NewFakePushGateway(ft)
+	defer gw.Close()
+
+	// The push package encodes values containing a slash, and empty values, with @base64
+	err := push.New(gw.URL(), "batch/nightly").Grouping("path", "/var/tmp").Grouping("instance", "").
+		Collector(newPushedCounter("processed_total", 3)).Push()
+	if err != nil {
+		t.Fatalf("Could not push: %v", err)
+	}
+	gw.Snapshot().AssertCount("processed_total", map[string]string{"job": "batch/nightly", "path": "/var/tmp", "instance": ""}, 3)
+	if len(ft.failures) != 0 {
+		t.Errorf("Expected no failure but got %q", ft.failures)
+	}
+}
+
+func TestFakePushGatewayRejections(t *testing.T) {
+	tests := []struct {
+		name, method, path, body string
+		status                   int
+	}{
+		{"grouping label conflict", http.MethodPut, "/metrics/job/batch", "processed_total{job=\"other\"} 1\n", http.StatusBadRequest},
+		{"missing job", http.MethodPut, "/metrics/instance/a", "processed_total 1\n", http.StatusBadRequest},
+		{"missing label value", http.MethodPut, "/metrics/job/batch/instance", "processed_total 1\n", http.StatusBadRequest},
+		{"invalid base64 value", http.MethodPut, "/metrics/job@base64/!!!", "processed_total 1\n", http.StatusBadRequest},
+		{"invalid exposition", http.MethodPost, "/metrics/job/batch", "processed_total one\n", http.StatusBadRequest},
+		{"GET", http.MethodGet, "/metrics/job/batch", "", http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gw := NewFakePushGateway(t)
+			req, err := http.NewRequest(tt.method, gw.URL()+tt.path, strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatalf("Could not create the request: %v", err)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("Could not send the request: %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.status {
+				t.Errorf("Expected the status to be %d but was %d", tt.status, resp.StatusCode)
+			}
+			if series := gw.Snapshot().MetricMap; len(series) != 0 {
+				t.Errorf("Expected nothing to be pushed but got %v", series)
+			}
+		})
+	}
+}
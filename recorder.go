@@ -0,0 +1,200 @@
+package promtest
+
+import (
+	"strings"
+	"sync"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// Recorder records a snapshot of a registry every time it is gathered, e.g. scraped through
+// promhttp.HandlerFor, to assert on the timeline of its metrics, such as metrics reset or expired
+// between scrapes.
+type Recorder struct {
+	r *TestRegistry
+
+	mu        sync.Mutex
+	snapshots []*Snapshot
+}
+
+// recorders holds the recorders of a registry that are recording.
+type recorders struct {
+	mu     sync.Mutex
+	active []*Recorder
+}
+
+// Gather gathers the registry like prometheus.Registry.Gather, recording a snapshot for every
+// Recorder that is recording. Snapshots taken with TakeSnapshot are not recorded.
+func (r *TestRegistry) Gather() ([]*dto.MetricFamily, error) {
+	families, err := r.Registry.Gather()
+	if err != nil {
+		return families, err
+	}
+	r.recorders.mu.Lock()
+	active := append([]*Recorder(nil), r.recorders.active...)
+	r.recorders.mu.Unlock()
+	if len(active) == 0 {
+		return families, nil
+	}
+
+	s := r.configure(NewSnapshotFromFamilies(r.t, families))
+	if s.MetricMap, err = r.rewrite(s.MetricMap, nil); err != nil {
+		r.t.Errorf("Could not record the gathered metrics: %v", err)
+		return families, nil
+	}
+	for _, rec := range active {
+		rec.mu.Lock()
+		rec.snapshots = append(rec.snapshots, s)
+		rec.mu.Unlock()
+	}
+	return families, nil
+}
+
+// StartRecording returns a Recorder recording a snapshot on every Gather of the registry until it
+// is stopped.
+func (r *TestRegistry) StartRecording() *Recorder {
+	rec := &Recorder{r: r}
+	r.recorders.mu.Lock()
+	r.recorders.active = append(r.recorders.active, rec)
+	r.recorders.mu.Unlock()
+	return rec
+}
+
+// Stop stops recording. The recorded snapshots are kept.
+func (rec *Recorder) Stop() {
+	rec.r.recorders.mu.Lock()
+	defer rec.r.recorders.mu.Unlock()
+	for i, active := range rec.r.recorders.active {
+		if active == rec {
+			rec.r.recorders.active = append(rec.r.recorders.active[:i], rec.r.recorders.active[i+1:]...)
+			return
+		}
+	}
+}
+
+// Snapshots returns the recorded snapshots in the order of the gathers.
+func (rec *Recorder) Snapshots() []*Snapshot {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	return append([]*Snapshot(nil), rec.snapshots...)
+}
+
+// AssertMonotonic asserts that a series never decreases from one recorded snapshot to the next.
+// The value of a counter, gauge or untyped series is compared, or the sample count of a summary or
+// histogram. A series missing from a snapshot has 0 value, so it must not disappear either.
+func (rec *Recorder) AssertMonotonic(name string, labels map[string]string) {
+	rec.r.t.Helper()
+	values, ok := rec.values(name, labels)
+	if !ok {
+		return
+	}
+	for i := 1; i < len(values); i++ {
+		if values[i] < values[i-1] {
+			rec.r.t.Errorf("Expected %s%s to never decrease but it decreased from %s in snapshot %d to %s in snapshot %d",
+				name, formatLabels(labels), formatFloat(values[i-1]), i-1, formatFloat(values[i]), i)
+			return
+		}
+	}
+}
+
+// AssertEventuallyReaches asserts that a series, compared like in AssertMonotonic, holds value in
+// at least one recorded snapshot, or satisfies the comparisons, if any. A series missing from a
+// snapshot has 0 value.
+func (rec *Recorder) AssertEventuallyReaches(name string, labels map[string]string, value float64, cmp ...Comparison) {
+	rec.r.t.Helper()
+	values, ok := rec.values(name, labels)
+	if !ok {
+		return
+	}
+	snapshots := rec.Snapshots()
+	formatted := make([]string, 0, len(values))
+	for i, actual := range values {
+		if snapshots[i].satisfies(value, actual, cmp) {
+			return
+		}
+		formatted = append(formatted, formatFloat(actual))
+	}
+	expected := formatFloat(value)
+	if len(cmp) > 0 {
+		expected = cmp[0].describe(value, formatFloat)
+		for _, c := range cmp[1:] {
+			expected += " and " + c.describe(value, formatFloat)
+		}
+	}
+	rec.r.t.Errorf("Expected %s%s to reach %s in one of %d snapshots but its values were [%s]",
+		name, formatLabels(labels), expected, len(values), strings.Join(formatted, ", "))
+}
+
+// AssertResetDetected asserts that at least one series of a metric family decreased or disappeared
+// from one recorded snapshot to the next, e.g. because its collector expired it.
+func (rec *Recorder) AssertResetDetected(name string) {
+	rec.r.t.Helper()
+	snapshots := rec.Snapshots()
+	if len(snapshots) == 0 {
+		rec.r.t.Errorf("No snapshot was recorded, is the registry gathered while recording?")
+		return
+	}
+	for i := 1; i < len(snapshots); i++ {
+		current := recordedSeries(snapshots[i], name)
+		for series, previous := range recordedSeries(snapshots[i-1], name) {
+			if value, ok := current[series]; !ok || value < previous {
+				return
+			}
+		}
+	}
+	rec.r.t.Errorf("Expected a reset of %s between two of %d snapshots but none was detected", name, len(snapshots))
+}
+
+// values returns the value of a series in every recorded snapshot, failing the test if there is no
+// snapshot or the series has no single value.
+func (rec *Recorder) values(name string, labels map[string]string) ([]float64, bool) {
+	rec.r.t.Helper()
+	snapshots := rec.Snapshots()
+	if len(snapshots) == 0 {
+		rec.r.t.Errorf("No snapshot was recorded, is the registry gathered while recording?")
+		return nil, false
+	}
+	values := make([]float64, 0, len(snapshots))
+	for _, s := range snapshots {
+		family, ok := s.family(name)
+		if !ok {
+			// Metric not existing is the same as the metric having 0 value
+			values = append(values, 0)
+			continue
+		}
+		metric, err := s.findMetric(family.GetType(), name, labels)
+		if err != nil && !isNotFound(err) {
+			s.report(name, err)
+			return nil, false
+		}
+		value, ok := recordedValue(family.GetType(), metric)
+		if !ok {
+			rec.r.t.Errorf("%s %s does not have a single value", typeTitle(family.GetType()), name)
+			return nil, false
+		}
+		values = append(values, value)
+	}
+	return values, true
+}
+
+// recordedSeries returns the value of every series of a metric family in a snapshot by its labels.
+func recordedSeries(s *Snapshot, name string) map[string]float64 {
+	family, _ := s.family(name)
+	series := make(map[string]float64, len(family.GetMetric()))
+	for _, m := range family.GetMetric() {
+		series[formatLabels(labelMap(m))], _ = recordedValue(family.GetType(), m)
+	}
+	return series
+}
+
+// recordedValue returns the value of a counter, gauge or untyped series, or the sample count of a
+// summary or histogram. A missing series has 0 value.
+func recordedValue(metricType dto.MetricType, m *dto.Metric) (float64, bool) {
+	switch metricType {
+	case dto.MetricType_SUMMARY:
+		return float64(m.GetSummary().GetSampleCount()), true
+	case dto.MetricType_HISTOGRAM:
+		return float64(m.GetHistogram().GetSampleCount()), true
+	}
+	return singleValue(metricType, m)
+}
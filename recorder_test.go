@@ -0,0 +1,131 @@
+package promtest
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// newRecordedCounter records the counter foo of a registry reporting to ft over four gathers, with
+// the values 1, 3, 0 after a reset and 2, and returns the stopped Recorder.
+func newRecordedCounter(t *testing.T, ft *fakeTB) *Recorder {
+	t.Helper()
+	r := NewTestRegistry(ft)
+	c := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "foo", Help: "foo"}, []string{"code"})
+	r.MustRegister(c)
+	rec := r.StartRecording()
+	gather := func() {
+		if _, err := r.Gather(); err != nil {
+			t.Fatalf("Could not gather: %v", err)
+		}
+	}
+	c.WithLabelValues("200").Add(1)
+	gather()
+	c.WithLabelValues("200").Add(2)
+	gather()
+	c.Reset()
+	gather()
+	c.WithLabelValues("200").Add(2)
+	gather()
+	rec.Stop()
+
+	// Neither gathers after Stop nor snapshots are recorded
+	c.WithLabelValues("200").Add(10)
+	gather()
+	if _, err := r.TakeSnapshot(); err != nil {
+		t.Fatalf("Could not take a snapshot: %v", err)
+	}
+	return rec
+}
+
+func TestRecorder(t *testing.T) {
+	labels := map[string]string{"code": "200"}
+	tests := []struct {
+		name    string
+		assert  func(rec *Recorder)
+		failure string
+	}{
+		{
+			name:   "AssertEventuallyReaches",
+			assert: func(rec *Recorder) { rec.AssertEventuallyReaches("foo", labels, 3) },
+		},
+		{
+			name:   "AssertEventuallyReaches with a comparison",
+			assert: func(rec *Recorder) { rec.AssertEventuallyReaches("foo", labels, 0, GreaterThan(2)) },
+		},
+		{
+			name:    "AssertEventuallyReaches a value never held",
+			assert:  func(rec *Recorder) { rec.AssertEventuallyReaches("foo", labels, 12) },
+			failure: `Expected foo{code="200"} to reach 12 in one of 4 snapshots but its values were [1, 3, 0, 2]`,
+		},
+		{
+			name:    "AssertEventuallyReaches a range never held",
+			assert:  func(rec *Recorder) { rec.AssertEventuallyReaches("foo", labels, 0, Between(4, 5)) },
+			failure: `Expected foo{code="200"} to reach between 4 and 5 in one of 4 snapshots`,
+		},
+		{
+			name:   "AssertResetDetected",
+			assert: func(rec *Recorder) { rec.AssertResetDetected("foo") },
+		},
+		{
+			name:    "AssertMonotonic of a reset counter",
+			assert:  func(rec *Recorder) { rec.AssertMonotonic("foo", labels) },
+			failure: `Expected foo{code="200"} to never decrease but it decreased from 3 in snapshot 1 to 0 in snapshot 2`,
+		},
+		{
+			name:   "AssertMonotonic of a missing series",
+			assert: func(rec *Recorder) { rec.AssertMonotonic("foo", map[string]string{"code": "500"}) },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ft := &fakeTB{}
+			rec := newRecordedCounter(t, ft)
+			if n := len(rec.Snapshots()); n != 4 {
+				t.Fatalf("Expected 4 recorded snapshots but got %d", n)
+			}
+			tt.assert(rec)
+			if tt.failure == "" {
+				if len(ft.failures) != 0 {
+					t.Errorf("Expected no failure but got %q", ft.failures)
+				}
+				return
+			}
+			assertSingleFailure(t, ft, tt.failure)
+		})
+	}
+}
+
+func TestRecorderWithoutReset(t *testing.T) {
+	ft := &fakeTB{}
+	r := NewTestRegistry(ft)
+	c := prometheus.NewCounter(prometheus.CounterOpts{Name: "foo", Help: "foo"})
+	r.MustRegister(c)
+	rec := r.StartRecording()
+	for i := 0; i < 3; i++ {
+		c.Inc()
+		if _, err := r.Gather(); err != nil {
+			t.Fatalf("Could not gather: %v", err)
+		}
+	}
+	rec.AssertMonotonic("foo", nil)
+	if len(ft.failures) != 0 {
+		t.Fatalf("Expected no failure but got %q", ft.failures)
+	}
+	rec.AssertResetDetected("foo")
+	assertSingleFailure(t, ft, "Expected a reset of foo between two of 3 snapshots but none was detected")
+}
+
+func TestRecorderWithoutSnapshots(t *testing.T) {
+	for name, assert := range map[string]func(rec *Recorder){
+		"AssertMonotonic":         func(rec *Recorder) { rec.AssertMonotonic("foo", nil) },
+		"AssertEventuallyReaches": func(rec *Recorder) { rec.AssertEventuallyReaches("foo", nil, 1) },
+		"AssertResetDetected":     func(rec *Recorder) { rec.AssertResetDetected("foo") },
+	} {
+		t.Run(name, func(t *testing.T) {
+			ft := &fakeTB{}
+			assert(NewTestRegistry(ft).StartRecording())
+			assertSingleFailure(t, ft, "No snapshot was recorded, is the registry gathered while recording?")
+		})
+	}
+}
@@ -0,0 +1,340 @@
+package promtest
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// FakeRemoteWriteReceiver is an in-memory receiver of the remote-write protocol, version 1.0,
+// serving over httptest, e.g. for tests of exporters writing to Prometheus.
+//
+//	rw := promtest.NewFakeRemoteWriteReceiver(t)
+//	exporter.Export(rw.URL())
+//	rw.Snapshot().AssertGauge("queue_length", map[string]string{"queue": "jobs"}, 3)
+//
+// The snapshot holds the latest sample of every series. Series are gauges unless the metadata of
+// a request declares them counters. Histograms and summaries appear as their _bucket, _sum and
+// _count series.
+type FakeRemoteWriteReceiver struct {
+	t      TB
+	server *httptest.Server
+
+	mu       sync.Mutex
+	series   map[string]*remoteSeries
+	metadata map[string]remoteMetadata
+}
+
+// remoteSeries is the latest sample of a series written to a FakeRemoteWriteReceiver
+type remoteSeries struct {
+	name        string
+	labels      map[string]string
+	value       float64
+	timestampMs int64
+}
+
+// remoteMetadata is the metadata of a metric family written to a FakeRemoteWriteReceiver
+type remoteMetadata struct {
+	metricType int32
+	help       string
+}
+
+// remoteCounterType is the value of the COUNTER metric type in the remote-write protocol
+const remoteCounterType = 1
+
+// NewFakeRemoteWriteReceiver starts a FakeRemoteWriteReceiver. It is closed when the test ends if t
+// implements Cleanup like testing.TB, otherwise Close must be called.
+func NewFakeRemoteWriteReceiver(t TB) *FakeRemoteWriteReceiver {
+	rw := &FakeRemoteWriteReceiver{
+		t:        t,
+		series:   make(map[string]*remoteSeries),
+		metadata: make(map[string]remoteMetadata),
+	}
+	rw.server = httptest.NewServer(http.HandlerFunc(rw.serveHTTP))
+	if ct, ok := t.(cleanupTB); ok {
+		ct.Cleanup(rw.Close)
+	}
+	return rw
+}
+
+// URL returns the URL to write to.
+func (rw *FakeRemoteWriteReceiver) URL() string {
+	return rw.server.URL + "/api/v1/write"
+}
+
+// Close shuts the FakeRemoteWriteReceiver down.
+func (rw *FakeRemoteWriteReceiver) Close() {
+	rw.server.Close()
+}
+
+// Snapshot creates a snapshot of the latest sample of every series written so far.
+func (rw *FakeRemoteWriteReceiver) Snapshot() *Snapshot {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	families := make(map[string]*dto.MetricFamily)
+	var ordered []*dto.MetricFamily
+	for _, series := range rw.series {
+		family, ok := families[series.name]
+		if !ok {
+			name, metadata := series.name, rw.metadata[series.name]
+			metricType := dto.MetricType_GAUGE
+			if metadata.metricType == remoteCounterType {
+				metricType = dto.MetricType_COUNTER
+			}
+			family = &dto.MetricFamily{Name: &name, Help: &metadata.help, Type: &metricType}
+			families[name] = family
+			ordered = append(ordered, family)
+		}
+
+		value, timestampMs := series.value, series.timestampMs
+		m := &dto.Metric{Label: labelPairs(series.labels), TimestampMs: &timestampMs}
+		if family.GetType() == dto.MetricType_COUNTER {
+			m.Counter = &dto.Counter{Value: &value}
+		} else {
+			m.Gauge = &dto.Gauge{Value: &value}
+		}
+		family.Metric = append(family.Metric, m)
+	}
+	return NewSnapshotFromFamilies(rw.t, ordered)
+}
+
+// serveHTTP handles a snappy compressed WriteRequest.
+func (rw *FakeRemoteWriteReceiver) serveHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Header.Get("Content-Encoding") == "snappy" {
+		if body, err = decodeSnappy(body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	if err := rw.decodeWriteRequest(body); err != nil {
+		http.Error(w, fmt.Sprintf("could not parse write request: %v", err), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// decodeWriteRequest records the series and metadata of a WriteRequest.
+func (rw *FakeRemoteWriteReceiver) decodeWriteRequest(b []byte) error {
+	return forEachField(b, func(num protowire.Number, typ protowire.Type, value []byte) error {
+		switch {
+		case num == 1 && typ == protowire.BytesType:
+			return rw.decodeTimeSeries(protowireBytes(value))
+		case num == 3 && typ == protowire.BytesType:
+			return rw.decodeMetadata(protowireBytes(value))
+		}
+		return nil
+	})
+}
+
+// decodeTimeSeries records the latest sample of a TimeSeries.
+func (rw *FakeRemoteWriteReceiver) decodeTimeSeries(b []byte) error {
+	labels := make(map[string]string)
+	var samples []remoteSeries
+	err := forEachField(b, func(num protowire.Number, typ protowire.Type, value []byte) error {
+		if typ != protowire.BytesType {
+			return nil
+		}
+		switch num {
+		case 1:
+			name, labelValue, err := decodeLabel(protowireBytes(value))
+			labels[name] = labelValue
+			return err
+		case 2:
+			sample, err := decodeSample(protowireBytes(value))
+			samples = append(samples, sample)
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	name := labels["__name__"]
+	if name == "" {
+		return errors.New("time series without a __name__ label")
+	}
+	delete(labels, "__name__")
+	key := name + formatLabels(labels)
+	for _, sample := range samples {
+		if latest, ok := rw.series[key]; ok && latest.timestampMs > sample.timestampMs {
+			continue
+		}
+		latest := sample
+		latest.name, latest.labels = name, labels
+		rw.series[key] = &latest
+	}
+	return nil
+}
+
+// decodeLabel decodes the name and value of a Label.
+func decodeLabel(b []byte) (name, value string, err error) {
+	err = forEachField(b, func(num protowire.Number, typ protowire.Type, fieldValue []byte) error {
+		switch {
+		case num == 1 && typ == protowire.BytesType:
+			name = string(protowireBytes(fieldValue))
+		case num == 2 && typ == protowire.BytesType:
+			value = string(protowireBytes(fieldValue))
+		}
+		return nil
+	})
+	return name, value, err
+}
+
+// decodeSample decodes the value and timestamp of a Sample.
+func decodeSample(b []byte) (remoteSeries, error) {
+	var sample remoteSeries
+	err := forEachField(b, func(num protowire.Number, typ protowire.Type, value []byte) error {
+		switch {
+		case num == 1 && typ == protowire.Fixed64Type:
+			bits, _ := protowire.ConsumeFixed64(value)
+			sample.value = math.Float64frombits(bits)
+		case num == 2 && typ == protowire.VarintType:
+			v, _ := protowire.ConsumeVarint(value)
+			sample.timestampMs = int64(v)
+		}
+		return nil
+	})
+	return sample, err
+}
+
+// decodeMetadata records the type and help of a MetricMetadata.
+func (rw *FakeRemoteWriteReceiver) decodeMetadata(b []byte) error {
+	var name string
+	var metadata remoteMetadata
+	err := forEachField(b, func(num protowire.Number, typ protowire.Type, value []byte) error {
+		switch {
+		case num == 1 && typ == protowire.VarintType:
+			v, _ := protowire.ConsumeVarint(value)
+			metadata.metricType = int32(v)
+		case num == 2 && typ == protowire.BytesType:
+			name = string(protowireBytes(value))
+		case num == 4 && typ == protowire.BytesType:
+			metadata.help = string(protowireBytes(value))
+		}
+		return nil
+	})
+	if name != "" {
+		rw.metadata[name] = metadata
+	}
+	return err
+}
+
+// forEachField calls fn with the number, wire type and encoded value of every field of a protobuf
+// message.
+func forEachField(b []byte, fn func(num protowire.Number, typ protowire.Type, value []byte) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		m := protowire.ConsumeFieldValue(num, typ, b)
+		if m < 0 {
+			return protowire.ParseError(m)
+		}
+		if err := fn(num, typ, b[:m]); err != nil {
+			return err
+		}
+		b = b[m:]
+	}
+	return nil
+}
+
+// protowireBytes returns the content of an encoded length-delimited field value.
+func protowireBytes(value []byte) []byte {
+	b, _ := protowire.ConsumeBytes(value)
+	return b
+}
+
+var errCorruptSnappy = errors.New("corrupt snappy block")
+
+// decodeSnappy decodes a block of the snappy format, which remote-write requests are compressed
+// with.
+func decodeSnappy(src []byte) ([]byte, error) {
+	length, n := binary.Uvarint(src)
+	if n <= 0 {
+		return nil, errCorruptSnappy
+	}
+	src = src[n:]
+	dst := make([]byte, 0, len(src))
+	for len(src) > 0 {
+		tag := src[0]
+		var copyLength, offset int
+		switch tag & 3 {
+		case 0:
+			literalLength := int(tag >> 2)
+			src = src[1:]
+			if literalLength >= 60 {
+				size := literalLength - 59
+				if len(src) < size {
+					return nil, errCorruptSnappy
+				}
+				literalLength = 0
+				for i := size - 1; i >= 0; i-- {
+					literalLength = literalLength<<8 | int(src[i])
+				}
+				src = src[size:]
+			}
+			literalLength++
+			if literalLength <= 0 || len(src) < literalLength {
+				return nil, errCorruptSnappy
+			}
+			dst = append(dst, src[:literalLength]...)
+			src = src[literalLength:]
+			continue
+		case 1:
+			if len(src) < 2 {
+				return nil, errCorruptSnappy
+			}
+			copyLength = 4 + int(tag>>2&7)
+			offset = int(tag&0xe0)<<3 | int(src[1])
+			src = src[2:]
+		case 2:
+			if len(src) < 3 {
+				return nil, errCorruptSnappy
+			}
+			copyLength = 1 + int(tag>>2)
+			offset = int(binary.LittleEndian.Uint16(src[1:]))
+			src = src[3:]
+		case 3:
+			if len(src) < 5 {
+				return nil, errCorruptSnappy
+			}
+			copyLength = 1 + int(tag>>2)
+			offset = int(binary.LittleEndian.Uint32(src[1:]))
+			src = src[5:]
+		}
+		if offset <= 0 || offset > len(dst) {
+			return nil, errCorruptSnappy
+		}
+		// Copies may overlap the bytes they append, so they are appended one by one
+		for i := 0; i < copyLength; i++ {
+			dst = append(dst, dst[len(dst)-offset])
+		}
+	}
+	if uint64(len(dst)) != length {
+		return nil, errCorruptSnappy
+	}
+	return dst, nil
+}
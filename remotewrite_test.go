@@ -0,0 +1,175 @@
+package promtest
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/golang/snappy"
+	"github.com/prometheus/prometheus/prompb"
+)
+
+// postWriteRequest posts a snappy compressed WriteRequest to the receiver, returning the status code.
+func postWriteRequest(t *testing.T, rw *FakeRemoteWriteReceiver, wr *prompb.WriteRequest) int {
+	t.Helper()
+	b, err := wr.Marshal()
+	if err != nil {
+		t.Fatalf("Could not marshal the write request: %v", err)
+	}
+	return postRemoteWrite(t, rw, snappy.Encode(nil, b))
+}
+
+func postRemoteWrite(t *testing.T, rw *FakeRemoteWriteReceiver, body []byte) int {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, rw.URL(), bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("Could not create the write request: %v", err)
+	}
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Could not post the write request: %v", err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func remoteSeriesOf(name string, labels map[string]string, samples ...prompb.Sample) prompb.TimeSeries {
+	ts := prompb.TimeSeries{Labels: []prompb.Label{{Name: "__name__", Value: name}}, Samples: samples}
+	for _, pair := range labelPairs(labels) {
+		ts.Labels = append(ts.Labels, prompb.Label{Name: pair.GetName(), Value: pair.GetValue()})
+	}
+	return ts
+}
+
+func TestFakeRemoteWriteReceiver(t *testing.T) {
+	ft := &fakeTB{}
+	rw := NewFakeRemoteWriteReceiver(ft)
+	defer rw.Close()
+	status := postWriteRequest(t, rw, &prompb.WriteRequest{
+		Timeseries: []prompb.TimeSeries{
+			remoteSeriesOf("http_requests_total", map[string]string{"code": "200"},
+				prompb.Sample{Value: 3, Timestamp: 1000}, prompb.Sample{Value: 5, Timestamp: 2000}),
+			remoteSeriesOf("http_requests_total", map[string]string{"code": "500"}, prompb.Sample{Value: 1, Timestamp: 2000}),
+			remoteSeriesOf("queue_length", map[string]string{"queue": "jobs"}, prompb.Sample{Value: 7, Timestamp: 2000}),
+		},
+		Metadata: []prompb.MetricMetadata{
+			{Type: prompb.MetricMetadata_COUNTER, MetricFamilyName: "http_requests_total", Help: "Requests handled."},
+		},
+	})
+	if status != http.StatusNoContent {
+		t.Fatalf("Expected the write request to be accepted but the status was %d", status)
+	}
+	// An older sample does not replace the latest one
+	postWriteRequest(t, rw, &prompb.WriteRequest{Timeseries: []prompb.TimeSeries{
+		remoteSeriesOf("queue_length", map[string]string{"queue": "jobs"}, prompb.Sample{Value: 2, Timestamp: 1000}),
+	}})
+
+	s := rw.Snapshot()
+	s.AssertCount("http_requests_total", map[string]string{"code": "200"}, 5)
+	s.AssertCount("http_requests_total", map[string]string{"code": "500"}, 1)
+	s.AssertGauge("queue_length", map[string]string{"queue": "jobs"}, 7)
+	s.AssertHelp("http_requests_total", "Requests handled.")
+	if len(ft.failures) != 0 {
+		t.Errorf("Expected no failure but got %q", ft.failures)
+	}
+}
+
+func TestFakeRemoteWriteReceiverManySeries(t *testing.T) {
+	ft := &fakeTB{}
+	rw := NewFakeRemoteWriteReceiver(ft)
+	defer rw.Close()
+	wr := &prompb.WriteRequest{}
+	for i := 0; i < 1000; i++ {
+		wr.Timeseries = append(wr.Timeseries, remoteSeriesOf("queue_length",
+			map[string]string{"queue": fmt.Sprintf("queue-%d", i), "instance": strings.Repeat("host", 20)},
+			prompb.Sample{Value: float64(i), Timestamp: 1000}))
+	}
+	if status := postWriteRequest(t, rw, wr); status != http.StatusNoContent {
+		t.Fatalf("Expected the write request to be accepted but the status was %d", status)
+	}
+
+	s := rw.Snapshot()
+	s.AssertSeriesCount("queue_length", 1000)
+	s.AssertGauge("queue_length", map[string]string{"queue": "queue-999", "instance": strings.Repeat("host", 20)}, 999)
+	if len(ft.failures) != 0 {
+		t.Errorf("Expected no failure but got %q", ft.failures)
+	}
+}
+
+func TestFakeRemoteWriteReceiverMalformed(t *testing.T) {
+	valid, err := (&prompb.WriteRequest{Timeseries: []prompb.TimeSeries{
+		remoteSeriesOf("queue_length", map[string]string{"queue": "jobs"}, prompb.Sample{Value: 7, Timestamp: 2000}),
+	}}).Marshal()
+	if err != nil {
+		t.Fatalf("Could not marshal the write request: %v", err)
+	}
+	compressed := snappy.Encode(nil, valid)
+	withoutName, err := (&prompb.WriteRequest{Timeseries: []prompb.TimeSeries{
+		{Labels: []prompb.Label{{Name: "queue", Value: "jobs"}}, Samples: []prompb.Sample{{Value: 1}}},
+	}}).Marshal()
+	if err != nil {
+		t.Fatalf("Could not marshal the write request: %v", err)
+	}
+
+	tests := map[string][]byte{
+		"truncated snappy":       compressed[:len(compressed)-3],
+		"uncompressed":           valid,
+		"truncated protobuf":     snappy.Encode(nil, valid[:len(valid)-3]),
+		"series without a name":  snappy.Encode(nil, withoutName),
+		"snappy length mismatch": append([]byte{0xff, 0x01}, compressed[1:]...),
+	}
+	for name, body := range tests {
+		t.Run(name, func(t *testing.T) {
+			rw := NewFakeRemoteWriteReceiver(t)
+			if status := postRemoteWrite(t, rw, body); status != http.StatusBadRequest {
+				t.Errorf("Expected the write request to be rejected but the status was %d", status)
+			}
+		})
+	}
+
+	rw := NewFakeRemoteWriteReceiver(t)
+	resp, err := http.Get(rw.URL())
+	if err != nil {
+		t.Fatalf("Could not get the receiver: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("Expected GET to be rejected but the status was %d", resp.StatusCode)
+	}
+}
+
+func TestDecodeSnappy(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	inputs := map[string][]byte{
+		"empty":      {},
+		"one byte":   {42},
+		"repetitive": bytes.Repeat([]byte("http_requests_total{code=\"200\"} "), 5000),
+	}
+	for _, size := range []int{59, 60, 61, 255, 256, 65535, 65536, 100000} {
+		b := make([]byte, size)
+		random.Read(b)
+		inputs[fmt.Sprintf("%d random bytes", size)] = b
+	}
+	for name, input := range inputs {
+		t.Run(name, func(t *testing.T) {
+			encoded := snappy.Encode(nil, input)
+			decoded, err := decodeSnappy(encoded)
+			if err != nil {
+				t.Fatalf("Could not decode: %v", err)
+			}
+			if !bytes.Equal(decoded, input) {
+				t.Fatalf("Expected the decoded block to be the input")
+			}
+			for n := 0; n < len(encoded) && n < 64; n++ {
+				if _, err := decodeSnappy(encoded[:len(encoded)-n-1]); err == nil && len(input) > 0 {
+					t.Errorf("Expected an error decoding the block without its last %d bytes", n+1)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,31 @@
+package promtest
+
+// RequireCount is AssertCount, stopping the test on failure.
+func (s *Snapshot) RequireCount(name string, labels map[string]string, value float64, cmp ...Comparison) {
+	s.t.Helper()
+	s.Require().AssertCount(name, labels, value, cmp...)
+}
+
+// RequireGauge is AssertGauge, stopping the test on failure.
+func (s *Snapshot) RequireGauge(name string, labels map[string]string, value float64, cmp ...Comparison) {
+	s.t.Helper()
+	s.Require().AssertGauge(name, labels, value, cmp...)
+}
+
+// RequireSummary is AssertSummary, stopping the test on failure.
+func (s *Snapshot) RequireSummary(name string, labels map[string]string, sum float64, count uint64, cmp ...Comparison) {
+	s.t.Helper()
+	s.Require().AssertSummary(name, labels, sum, count, cmp...)
+}
+
+// RequireHistogram is AssertHistogram, stopping the test on failure.
+func (s *Snapshot) RequireHistogram(name string, labels map[string]string, sum float64, count uint64, cmp ...Comparison) {
+	s.t.Helper()
+	s.Require().AssertHistogram(name, labels, sum, count, cmp...)
+}
+
+// RequireExists is AssertExists, stopping the test on failure.
+func (s *Snapshot) RequireExists(name string, labels map[string]string) {
+	s.t.Helper()
+	s.Require().AssertExists(name, labels)
+}
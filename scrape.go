@@ -0,0 +1,99 @@
+package promtest
+
+import (
+	"sort"
+)
+
+// TakeSnapshotAfterScrapes gathers the registry n-1 times, discarding the results, and then takes a
+// snapshot of the nth gather. This is useful for collectors that keep state between scrapes, such
+// as collectors reporting deltas, which only expose meaningful values after warming up.
+func (r *TestRegistry) TakeSnapshotAfterScrapes(n int) (*Snapshot, error) {
+	for i := 1; i < n; i++ {
+		if _, err := r.Registry.Gather(); err != nil {
+			return nil, err
+		}
+	}
+	return r.TakeSnapshot()
+}
+
+// AssertStableAcrossScrapes asserts that gathering the registry n times always yields the same
+// metric families with the same series. The values of the series may change between scrapes.
+func (r *TestRegistry) AssertStableAcrossScrapes(n int) {
+	r.t.Helper()
+	var first map[string][]string
+	for i := 0; i < n; i++ {
+		snapshot, err := r.TakeSnapshot()
+		if err != nil {
+			r.t.Errorf("Could not gather scrape %d: %v", i, err)
+			return
+		}
+		series := seriesByFamily(snapshot)
+		if first == nil {
+			first = series
+			continue
+		}
+		if diff := diffSeries(first, series); diff != "" {
+			r.t.Errorf("Scrape %d differs from scrape 0: %s", i, diff)
+			return
+		}
+	}
+}
+
+// seriesByFamily returns the sorted label sets of the series of every family in a snapshot.
+func seriesByFamily(s *Snapshot) map[string][]string {
+	series := make(map[string][]string, len(s.MetricMap))
+	for name, family := range s.MetricMap {
+		labelSets := make([]string, 0, len(family.GetMetric()))
+		for _, m := range family.GetMetric() {
+			labelSets = append(labelSets, formatLabels(labelMap(m)))
+		}
+		sort.Strings(labelSets)
+		series[name] = labelSets
+	}
+	return series
+}
+
+// diffSeries describes the first difference between two results of seriesByFamily, or returns ""
+// if they are the same.
+func diffSeries(expected, actual map[string][]string) string {
+	for _, name := range sortedKeys(expected) {
+		actualSets, ok := actual[name]
+		if !ok {
+			return "metric " + name + " is missing"
+		}
+		for _, labels := range expected[name] {
+			if !containsString(actualSets, labels) {
+				return "series " + name + labels + " is missing"
+			}
+		}
+		for _, labels := range actualSets {
+			if !containsString(expected[name], labels) {
+				return "series " + name + labels + " was added"
+			}
+		}
+	}
+	for _, name := range sortedKeys(actual) {
+		if _, ok := expected[name]; !ok {
+			return "metric " + name + " was added"
+		}
+	}
+	return ""
+}
+
+func sortedKeys(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,171 @@
+package promtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	dto "github.com/prometheus/client_model/go"
+	"gopkg.in/yaml.v3"
+)
+
+// MetricSpec declares the expected series of a snapshot, e.g. the metric contract of a service
+type MetricSpec []SeriesSpec
+
+// SeriesSpec declares the expected value of a series. The series has exactly Labels, or if Match is
+// set, every series with at least Labels and labels fully matching the regular expressions of Match
+// must meet the spec. No series matching is the same as a single series having 0 value. Value, Min
+// and Max apply to counters and gauges, Sum and Count to summaries and histograms. Unset fields are
+// not checked.
+type SeriesSpec struct {
+	Name   string            `json:"name" yaml:"name"`
+	Type   string            `json:"type" yaml:"type"`
+	Labels map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+	Match  map[string]string `json:"match,omitempty" yaml:"match,omitempty"`
+	Value  *float64          `json:"value,omitempty" yaml:"value,omitempty"`
+	Min    *float64          `json:"min,omitempty" yaml:"min,omitempty"`
+	Max    *float64          `json:"max,omitempty" yaml:"max,omitempty"`
+	Sum    *float64          `json:"sum,omitempty" yaml:"sum,omitempty"`
+	Count  *uint64           `json:"count,omitempty" yaml:"count,omitempty"`
+}
+
+// LoadSpec reads a MetricSpec from a file holding a list of series specs, in YAML if its extension
+// is .yaml or .yml and in JSON otherwise, e.g. that no request failed, whether or not a series of
+// failed requests exists:
+//
+//	[{"name": "http_requests_total", "type": "counter", "match": {"code": "5.."}, "value": 0}]
+//
+// or in YAML:
+//
+//   - name: http_requests_total
+//     type: counter
+//     match: {code: "5.."}
+//     value: 0
+func LoadSpec(path string) (MetricSpec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	unmarshal := json.Unmarshal
+	if ext := strings.ToLower(filepath.Ext(path)); ext == ".yaml" || ext == ".yml" {
+		unmarshal = yaml.Unmarshal
+	}
+	var spec MetricSpec
+	if err := unmarshal(data, &spec); err != nil {
+		return nil, fmt.Errorf("could not parse metric spec %s: %v", path, err)
+	}
+	return spec, nil
+}
+
+// AssertSpec asserts every series spec of spec against the snapshot.
+func (s *Snapshot) AssertSpec(spec MetricSpec) {
+	s.t.Helper()
+	for _, series := range spec {
+		s.assertSeriesSpec(series)
+	}
+}
+
+// assertSeriesSpec resolves the series of a spec and asserts each of its set fields.
+func (s *Snapshot) assertSeriesSpec(series SeriesSpec) {
+	s.t.Helper()
+	metricType, ok := dto.MetricType_value[strings.ToUpper(series.Type)]
+	if !ok {
+		s.errorf(series.Name, "Invalid type %q in the spec of %s", series.Type, series.Name)
+		return
+	}
+
+	labelSets := []map[string]string{series.Labels}
+	if len(series.Match) > 0 {
+		var matched bool
+		if labelSets, matched = s.specLabelSets(dto.MetricType(metricType), series); !matched {
+			return
+		}
+	}
+	for _, labels := range labelSets {
+		a := &MetricAssertion{s: s, metricType: dto.MetricType(metricType), name: series.Name, labels: labels}
+		if series.Value != nil {
+			a.Equals(*series.Value)
+		}
+		if series.Sum != nil {
+			a.SumEquals(*series.Sum)
+		}
+		if series.Count != nil {
+			a.CountEquals(*series.Count)
+		}
+		if series.Min != nil || series.Max != nil {
+			s.assertSpecRange(series, a.metricType, labels)
+		}
+	}
+}
+
+// specLabelSets returns the labels of every series matched by a spec with Match. No matching series
+// is the same as a single series having 0 value, which is asserted right away, and false is returned.
+func (s *Snapshot) specLabelSets(metricType dto.MetricType, series SeriesSpec) ([]map[string]string, bool) {
+	s.t.Helper()
+	if err := s.typeError(metricType, series.Name); err != nil {
+		s.report(series.Name, err)
+		return nil, false
+	}
+	matchers := MatchSubset(series.Labels)
+	names := make([]string, 0, len(series.Match))
+	for name := range series.Match {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		matchers = append(matchers, Label(name, series.Match[name]).Regex())
+	}
+
+	family, _ := s.family(series.Name)
+	var labelSets []map[string]string
+	for _, m := range family.GetMetric() {
+		labels := labelMap(m)
+		ok, err := matchesAll(matchers, labels)
+		if err != nil {
+			s.report(series.Name, err)
+			return nil, false
+		}
+		if ok {
+			labelSets = append(labelSets, labels)
+		}
+	}
+	if len(labelSets) > 0 {
+		return labelSets, true
+	}
+
+	// No matching series is the same as a series having 0 value
+	selector := series.Name + formatMatchers(matchers)
+	switch {
+	case series.Value != nil && !s.floatEquals(*series.Value, 0):
+		s.errorf(series.Name, "Expected %s to be %f but no series matches", selector, *series.Value)
+	case series.Sum != nil && !s.floatEquals(*series.Sum, 0):
+		s.errorf(series.Name, "Expected the sample sum of %s to be %f but no series matches", selector, *series.Sum)
+	case series.Count != nil && *series.Count != 0:
+		s.errorf(series.Name, "Expected the sample count of %s to be %d but no series matches", selector, *series.Count)
+	case series.Min != nil && *series.Min > 0:
+		s.errorf(series.Name, "Expected %s to be at least %f but no series matches", selector, *series.Min)
+	case series.Max != nil && *series.Max < 0:
+		s.errorf(series.Name, "Expected %s to be at most %f but no series matches", selector, *series.Max)
+	}
+	return nil, false
+}
+
+// assertSpecRange asserts that a counter or gauge is within the Min and Max of its spec.
+func (s *Snapshot) assertSpecRange(series SeriesSpec, metricType dto.MetricType, labels map[string]string) {
+	s.t.Helper()
+	value, ok := s.seriesValue(metricType, series.Name, labels)
+	if !ok {
+		return
+	}
+	if series.Min != nil && value < *series.Min {
+		s.errorf(series.Name, "Expected %s %s with the labels %v to be at least %f but was %f",
+			strings.ToLower(typeTitle(metricType)), series.Name, labels, *series.Min, value)
+	}
+	if series.Max != nil && value > *series.Max {
+		s.errorf(series.Name, "Expected %s %s with the labels %v to be at most %f but was %f",
+			strings.ToLower(typeTitle(metricType)), series.Name, labels, *series.Max, value)
+	}
+}
@@ -0,0 +1,53 @@
+package promtest
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadSpec(t *testing.T) {
+	jsonSpec, err := LoadSpec(filepath.Join("testdata", "spec.json"))
+	if err != nil {
+		t.Fatalf("Could not load the JSON spec: %v", err)
+	}
+	yamlSpec, err := LoadSpec(filepath.Join("testdata", "spec.yaml"))
+	if err != nil {
+		t.Fatalf("Could not load the YAML spec: %v", err)
+	}
+	if !reflect.DeepEqual(jsonSpec, yamlSpec) {
+		t.Errorf("Expected the YAML spec to be the JSON spec %+v but was %+v", jsonSpec, yamlSpec)
+	}
+
+	ft := &fakeTB{}
+	newSnapshotOf(t, ft, newFooCounter(), newBarGauge()).AssertSpec(yamlSpec)
+	if len(ft.failures) != 0 {
+		t.Errorf("Expected no failure but got %q", ft.failures)
+	}
+	newSnapshotOf(t, ft, newFooCounter()).AssertSpec(yamlSpec)
+	assertSingleFailure(t, ft, "Could not find Gauge bar")
+}
+
+func TestLoadSpecErrors(t *testing.T) {
+	dir := t.TempDir()
+	tests := map[string]string{
+		"invalid.json": `[{"name": "foo", "type": "counter", "value": "one"}]`,
+		"invalid.yml":  "- name: foo\n  value: [1]\n",
+		"yaml.json":    "- name: foo\n",
+	}
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, name)
+			if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+				t.Fatalf("Could not write the spec: %v", err)
+			}
+			if _, err := LoadSpec(path); err == nil {
+				t.Errorf("Expected an error loading %s", name)
+			}
+		})
+	}
+	if _, err := LoadSpec(filepath.Join(dir, "missing.json")); err == nil {
+		t.Errorf("Expected an error loading a missing spec")
+	}
+}
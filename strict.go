@@ -0,0 +1,87 @@
+package promtest
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Strict makes the test fail when it ends if a metric family of the registry has series but was
+// never asserted on by any snapshot of the registry. The go_* and process_* metrics are ignored.
+// It requires the TB of the registry to implement Cleanup, Failed and Logf like testing.TB, or the
+// test fails.
+func Strict() Option {
+	return func(r *TestRegistry) {
+		r.strict = true
+	}
+}
+
+// assertedFamilies records the names of the metric families assertions looked up, shared by the
+// snapshots of a registry. The names are also recorded by the trackers it forwards to, e.g. those
+// of the snapshots combined by a MultiSnapshot.
+type assertedFamilies struct {
+	mu        sync.Mutex
+	names     map[string]bool
+	forwardTo []*assertedFamilies
+}
+
+func newAssertedFamilies(forwardTo ...*assertedFamilies) *assertedFamilies {
+	return &assertedFamilies{names: make(map[string]bool), forwardTo: forwardTo}
+}
+
+func (a *assertedFamilies) add(name string) {
+	a.mu.Lock()
+	a.names[name] = true
+	a.mu.Unlock()
+	for _, other := range a.forwardTo {
+		other.add(name)
+	}
+}
+
+func (a *assertedFamilies) contains(name string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.names[name]
+}
+
+// AssertNoUnexpectedMetrics asserts that every metric family of the snapshot with series was
+// asserted on by this snapshot or, for a snapshot of a TestRegistry, by any snapshot of the
+// registry. The go_* and process_* metrics are ignored.
+func (s *Snapshot) AssertNoUnexpectedMetrics() {
+	s.t.Helper()
+	if names := s.unassertedFamilies(); len(names) > 0 {
+		s.errorf("", "Found %d metrics that were never asserted on: %s", len(names), strings.Join(names, ", "))
+	}
+}
+
+// unassertedFamilies returns the sorted names of the metric families of the snapshot with series
+// that were never asserted on.
+func (s *Snapshot) unassertedFamilies() []string {
+	var names []string
+	for name, family := range s.MetricMap {
+		if len(family.GetMetric()) > 0 && !isRuntimeMetric(name) && !s.asserted.contains(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// assertNoUnassertedMetrics gathers the registry at the end of a strict test and fails it if a
+// metric family was never asserted on.
+func (r *TestRegistry) assertNoUnassertedMetrics() {
+	r.t.Helper()
+	families, err := r.Registry.Gather()
+	if err != nil {
+		r.t.Errorf("Could not gather metrics to check for unasserted metrics: %v", err)
+		return
+	}
+	s := r.configure(NewSnapshotFromFamilies(r.t, families))
+	if s.MetricMap, err = r.rewrite(s.MetricMap, nil); err != nil {
+		r.t.Errorf("Could not rewrite metrics to check for unasserted metrics: %v", err)
+		return
+	}
+	if names := s.unassertedFamilies(); len(names) > 0 {
+		r.t.Errorf("Strict registry has %d metrics that were never asserted on: %s", len(names), strings.Join(names, ", "))
+	}
+}
@@ -0,0 +1,51 @@
+package promtest
+
+import (
+	"math"
+	"strings"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// AssertSummaryQuantile asserts the value of a quantile of a summary in the snapshot, e.g. 0.99 for
+// the 99th percentile.
+func (s *Snapshot) AssertSummaryQuantile(name string, labels map[string]string, quantile float64, value float64) {
+	s.t.Helper()
+	metric, err := s.findMetric(dto.MetricType_SUMMARY, name, labels)
+	if err != nil {
+		s.report(name, err)
+		return
+	}
+
+	summary := metric.GetSummary()
+	var q *dto.Quantile
+	for _, candidate := range summary.GetQuantile() {
+		if floatEquals(candidate.GetQuantile(), quantile, defaultTolerance) {
+			q = candidate
+			break
+		}
+	}
+	if q == nil {
+		s.errorf(name, "Summary %s has no quantile %f, its quantiles are %s", name, quantile, formatQuantiles(summary))
+		return
+	}
+
+	actualValue := q.GetValue()
+	if math.IsNaN(actualValue) && !math.IsNaN(value) {
+		s.errorf(name, "Expected summary [%s] quantile %f to be %f but was NaN, does the summary have observations?",
+			name, quantile, value)
+		return
+	}
+	if !s.floatEquals(actualValue, value) {
+		s.errorf(name, "Expected summary [%s] quantile %f to be %f but was %f", name, quantile, value, actualValue)
+	}
+}
+
+// formatQuantiles lists the quantiles of a summary.
+func formatQuantiles(summary *dto.Summary) string {
+	quantiles := make([]string, 0, len(summary.GetQuantile()))
+	for _, q := range summary.GetQuantile() {
+		quantiles = append(quantiles, formatFloat(q.GetQuantile()))
+	}
+	return "[" + strings.Join(quantiles, ", ") + "]"
+}